@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// addoptions.go lets a single `add` override the channel-wide defaults
+
+// movieAvailabilities are radarr's minimum availability values
+var movieAvailabilities = []string{"announced", "inCinemas", "released"}
+
+// addOptions are the flags `add` understands
+type addOptions struct {
+	// quality is a quality profile name or id
+	quality string
+	// folder is a root folder path or id
+	folder       string
+	availability string
+	noSearch     bool
+	unmonitored  bool
+	tags         []string
+	// seriesType, language and seasonFolders only apply to shows
+	seriesType string
+	// language is a language profile name or id
+	language      string
+	seasonFolders *bool
+}
+
+// parseAddArgs separates the flags of an `add` from the words naming the media
+func parseAddArgs(args []string) ([]string, addOptions, error) {
+	var media []string
+	var options addOptions
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch arg {
+		case "--no-search":
+			options.noSearch = true
+		case "--unmonitored":
+			options.unmonitored = true
+		case "--season-folders", "--no-season-folders":
+			seasonFolders := arg == "--season-folders"
+			options.seasonFolders = &seasonFolders
+		case "--quality", "--folder", "--availability", "--tags", "--type", "--language":
+			if i+1 >= len(args) {
+				return media, options, fmt.Errorf("`%s` needs a value", arg)
+			}
+
+			i++
+
+			switch arg {
+			case "--quality":
+				options.quality = args[i]
+			case "--folder":
+				options.folder = args[i]
+			case "--availability":
+				options.availability = args[i]
+			case "--type":
+				options.seriesType = args[i]
+			case "--language":
+				options.language = args[i]
+			case "--tags":
+				for _, tag := range strings.Split(args[i], ",") {
+					if tag = strings.TrimSpace(tag); tag != "" {
+						options.tags = append(options.tags, tag)
+					}
+				}
+			}
+		default:
+			if strings.HasPrefix(arg, "--") {
+				return media, options, fmt.Errorf("unknown option `%s`", arg)
+			}
+
+			media = append(media, arg)
+		}
+	}
+
+	return media, options, nil
+}
+
+// showOnly returns the first flag given that only applies to shows
+func (options addOptions) showOnly() string {
+	switch {
+	case options.seriesType != "":
+		return "--type"
+	case options.language != "":
+		return "--language"
+	case options.seasonFolders != nil:
+		return "--season-folders"
+	}
+
+	return ""
+}
+
+// addOnly returns the first flag given that only makes sense for a single add
+func (options addOptions) addOnly() string {
+	switch {
+	case options.quality != "":
+		return "--quality"
+	case options.folder != "":
+		return "--folder"
+	case options.availability != "":
+		return "--availability"
+	case options.noSearch:
+		return "--no-search"
+	case options.unmonitored:
+		return "--unmonitored"
+	case len(options.tags) > 0:
+		return "--tags"
+	}
+
+	return ""
+}
+
+// resolveAvailability matches an availability regardless of case
+func resolveAvailability(availability string) (string, error) {
+	for _, valid := range movieAvailabilities {
+		if strings.EqualFold(availability, valid) {
+			return valid, nil
+		}
+	}
+
+	return "", fmt.Errorf("`%s` is not an availability, use one of %s", availability, strings.Join(movieAvailabilities, ", "))
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// arr.go calls radarr and sonarr endpoints the api clients don't cover
+
+// arrAPI talks to a radarr or sonarr server
+type arrAPI struct {
+	// name is the backend name used in logs and errors
+	name   string
+	url    string
+	apiKey string
+	// root is where the api lives on the server, legacyAPIRoot or v3APIRoot
+	root string
+	// client overrides arrHTTPClient for slow endpoints
+	client *http.Client
+}
+
+// systemStatus is the part of /system/status shart uses
+type systemStatus struct {
+	Version     string `json:"version"`
+	Branch      string `json:"branch"`
+	StartTime   string `json:"startTime"`
+	StartupPath string `json:"startupPath"`
+	AppData     string `json:"appData"`
+	OsName      string `json:"osName"`
+	URLBase     string `json:"urlBase"`
+}
+
+const (
+	// legacyAPIRoot is the v2 api the go clients were written for
+	legacyAPIRoot = "/api"
+	// v3APIRoot is the api radarr and sonarr v3 and later speak
+	v3APIRoot = "/api/v3"
+)
+
+// errAPIKeyRejected is returned when radarr or sonarr answer 401
+var errAPIKeyRejected = errors.New("rejected the api key")
+
+var arrHTTPClient = http.Client{
+	Timeout: 5 * time.Second,
+}
+
+func newArrAPI(name, host, apiKey string) arrAPI {
+	return arrAPI{
+		name:   name,
+		url:    strings.TrimSuffix(host, "/"),
+		apiKey: apiKey,
+		root:   legacyAPIRoot,
+	}
+}
+
+// withRoot returns a copy of api whose endpoints live under root
+func (api arrAPI) withRoot(root string) arrAPI {
+	api.root = root
+
+	return api
+}
+
+// withTimeout returns a copy of api whose requests may take up to timeout
+func (api arrAPI) withTimeout(timeout time.Duration) arrAPI {
+	api.client = &http.Client{Timeout: timeout}
+
+	return api
+}
+
+// get decodes the json response of endpoint into result
+func (api arrAPI) get(endpoint string, params url.Values, result interface{}) error {
+	requestURL := api.url + api.root + endpoint
+
+	if len(params) > 0 {
+		requestURL += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequest("GET", requestURL, nil)
+
+	if err != nil {
+		return err
+	}
+
+	return api.do(req, result)
+}
+
+// post sends payload as json to endpoint and decodes the response into result
+func (api arrAPI) post(endpoint string, payload, result interface{}) error {
+	body, err := json.Marshal(payload)
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", api.url+api.root+endpoint, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	return api.do(req, result)
+}
+
+func (api arrAPI) do(req *http.Request, result interface{}) error {
+	req.Header.Set("X-Api-Key", api.apiKey)
+
+	client := &arrHTTPClient
+
+	if api.client != nil {
+		client = api.client
+	}
+
+	resp, err := client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("%s %w", api.name, errAPIKeyRejected)
+	}
+
+	// validation failures explain themselves, e.g. `This series has already been added`
+	if resp.StatusCode == http.StatusBadRequest {
+		var failures []struct {
+			ErrorMessage string `json:"errorMessage"`
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(&failures); err == nil && len(failures) > 0 {
+			var messages []string
+
+			for _, failure := range failures {
+				messages = append(messages, failure.ErrorMessage)
+			}
+
+			return errors.New(strings.Join(messages, "; "))
+		}
+	}
+
+	// posting a command answers 201
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return errors.New(resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// systemStatus returns the server's version and runtime information
+func (api arrAPI) systemStatus() (systemStatus, error) {
+	var status systemStatus
+
+	err := api.get("/system/status", nil, &status)
+
+	return status, err
+}
+
+// healthCheck is a problem radarr or sonarr noticed, e.g. an unavailable indexer
+type healthCheck struct {
+	Source  string `json:"source"`
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	WikiURL string `json:"wikiUrl"`
+}
+
+// health returns the problems the server currently knows about
+func (api arrAPI) health() ([]healthCheck, error) {
+	var checks []healthCheck
+
+	err := api.get("/health", nil, &checks)
+
+	return checks, err
+}
+
+// commandStatus is a command radarr or sonarr queued or ran
+type commandStatus struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// State is used by v2 of the api, Status by v3
+	State  string `json:"state"`
+	Status string `json:"status"`
+}
+
+// state returns where the command is at: queued, started, completed or failed
+func (c commandStatus) state() string {
+	if c.Status != "" {
+		return c.Status
+	}
+
+	return c.State
+}
+
+// runCommand queues a command such as `EpisodeSearch`. body holds the
+// command's arguments
+func (api arrAPI) runCommand(name string, body map[string]interface{}) (commandStatus, error) {
+	var status commandStatus
+
+	payload := map[string]interface{}{"name": name}
+
+	for key, value := range body {
+		payload[key] = value
+	}
+
+	err := api.post("/command", payload, &status)
+
+	return status, err
+}
+
+// command returns the current state of a command
+func (api arrAPI) command(id int) (commandStatus, error) {
+	var status commandStatus
+
+	err := api.get(fmt.Sprintf("/command/%d", id), nil, &status)
+
+	return status, err
+}
+
+// finished reports whether a command stopped running, successfully or not
+func (c commandStatus) finished() bool {
+	switch c.state() {
+	case "completed", "failed", "aborted", "cancelled", "orphaned":
+		return true
+	}
+
+	return false
+}
+
+// waitForCommand polls a command every interval until it finishes or timeout passes
+func (api arrAPI) waitForCommand(id int, interval, timeout time.Duration) (commandStatus, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		status, err := api.command(id)
+
+		if err != nil || status.finished() {
+			return status, err
+		}
+
+		if time.Now().After(deadline) {
+			return status, fmt.Errorf("still %s after %v", status.state(), timeout)
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// tag is a label radarr or sonarr can attach to media
+type tag struct {
+	ID    int    `json:"id"`
+	Label string `json:"label"`
+}
+
+// tagIDs returns the ids of tags by label, creating the ones that don't exist yet
+func (api arrAPI) tagIDs(labels []string) ([]int, error) {
+	var existing []tag
+
+	if err := api.get("/tag", nil, &existing); err != nil {
+		return nil, err
+	}
+
+	var ids []int
+
+	for _, label := range labels {
+		found := false
+
+		for _, t := range existing {
+			if strings.EqualFold(t.Label, label) {
+				ids = append(ids, t.ID)
+				found = true
+				break
+			}
+		}
+
+		if found {
+			continue
+		}
+
+		var created tag
+
+		if err := api.post("/tag", tag{Label: strings.ToLower(label)}, &created); err != nil {
+			return ids, fmt.Errorf("create tag `%s` failed: %v", label, err)
+		}
+
+		ids = append(ids, created.ID)
+	}
+
+	return ids, nil
+}
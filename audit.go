@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// audit.go records who changed what through shart
+
+// auditedCommands are the commands that change radarr, sonarr, shart or discord
+// along with the subcommand they change things with, empty when every run does
+// and `--` when only runs given flags do
+var auditedCommands = map[string]string{
+	"add":         "",
+	"set-quality": "",
+	"set-folder":  "",
+	"clear":       "",
+	"episode":     "search",
+	"season":      "",
+	"wanted":      "search",
+	"grab":        "",
+	"defaults":    "--",
+}
+
+// isAudited reports whether running cmd with args changes something worth recording
+func isAudited(cmd string, args []string) bool {
+	subcommand, ok := auditedCommands[cmd]
+
+	if !ok {
+		return false
+	}
+
+	switch subcommand {
+	case "":
+		return true
+	case "--":
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "--") {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return len(args) > 0 && args[0] == subcommand
+}
+
+// maxAuditDays is the longest `audit` looks back, numbers above it are user ids
+const maxAuditDays = 3650
+
+// auditTrail is where audited commands are recorded
+var auditTrail *auditLog
+
+// auditEntry is a single audited command
+type auditEntry struct {
+	Time      time.Time `json:"time"`
+	GuildID   string    `json:"guild_id"`
+	ChannelID string    `json:"channel_id"`
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	Command   string    `json:"command"`
+	Args      []string  `json:"args"`
+	Outcome   string    `json:"outcome"`
+	// Reply is the last thing shart said in response
+	Reply string `json:"reply"`
+}
+
+// auditLog is an append-only file of json encoded audit entries, one per line
+type auditLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+func newAuditLog(dataDir string) *auditLog {
+	return &auditLog{path: filepath.Join(dataDir, "audit.jsonl")}
+}
+
+// record appends an entry to the log
+func (a *auditLog) record(entry auditEntry) error {
+	line, err := json.Marshal(entry)
+
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	file, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// query returns entries newer than since, oldest first
+// user matches either the user id or the username and is ignored when empty
+func (a *auditLog) query(user string, since time.Time) ([]auditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var entries []auditEntry
+
+	file, err := os.Open(a.path)
+
+	if os.IsNotExist(err) {
+		return entries, nil
+	} else if err != nil {
+		return entries, err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		var entry auditEntry
+
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return entries, fmt.Errorf("corrupt audit entry: %v", err)
+		}
+
+		if entry.Time.Before(since) {
+			continue
+		}
+
+		if user != "" && entry.UserID != user && !strings.EqualFold(entry.Username, user) {
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// mentionedUserID strips discord's mention syntax (<@123> or <@!123>)
+func mentionedUserID(arg string) string {
+	if strings.HasPrefix(arg, "<@") && strings.HasSuffix(arg, ">") {
+		return strings.TrimPrefix(arg[2:len(arg)-1], "!")
+	}
+
+	return arg
+}
+
+func auditCSV(entries []auditEntry) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w := csv.NewWriter(&buf)
+
+	w.Write([]string{"time", "guild_id", "channel_id", "user_id", "username", "command", "args", "outcome", "reply"})
+
+	for _, entry := range entries {
+		w.Write([]string{
+			entry.Time.UTC().Format(time.RFC3339),
+			entry.GuildID,
+			entry.ChannelID,
+			entry.UserID,
+			entry.Username,
+			entry.Command,
+			strings.Join(entry.Args, " "),
+			entry.Outcome,
+			entry.Reply,
+		})
+	}
+
+	w.Flush()
+
+	return buf.Bytes(), w.Error()
+}
+
+func showAudit(commandList d, trail *auditLog) func(channelID string, args ...string) {
+	return func(channelID string, args ...string) {
+		// command: audit [csv] [user] [days]
+		//
+		// examples:
+		// audit
+		// audit 30
+		// audit @someone
+		// audit 123456789012345678
+		// audit csv @someone 30
+		exportCSV := false
+
+		if len(args) > 0 && args[0] == "csv" {
+			exportCSV = true
+			args = args[1:]
+		}
+
+		user := ""
+		days := 7
+
+		for _, arg := range args {
+			// raw discord ids are numbers too but far too big to be a day count
+			if n, err := strconv.Atoi(arg); err == nil && n > 0 && n <= maxAuditDays {
+				days = n
+			} else if arg != "" {
+				user = mentionedUserID(arg)
+			}
+		}
+
+		entries, err := trail.query(user, time.Now().AddDate(0, 0, -days))
+
+		if err != nil {
+			channelLogger(channelID).Error("read audit log failed", "error", err)
+			commandList.showError(channelID, fmt.Sprintf("could not read the audit log: %v", err))
+			return
+		}
+
+		summary := fmt.Sprintf("%d audited commands in the last %d days", len(entries), days)
+
+		if user != "" {
+			summary += " by `" + user + "`"
+		}
+
+		if exportCSV {
+			export, err := auditCSV(entries)
+
+			if err != nil {
+				commandList.showError(channelID, fmt.Sprintf("could not export the audit log: %v", err))
+				return
+			}
+
+			commandList.sendFile(channelID, summary, "shart-audit.csv", bytes.NewReader(export))
+			return
+		}
+
+		output := summary + ":\n"
+
+		// newest first and only as many as fit in one discord message
+		for i := len(entries) - 1; i >= 0; i-- {
+			entry := entries[i]
+
+			line := fmt.Sprintf("`%s` %s `%s %s` - %s\n",
+				entry.Time.Local().Format("2006-01-02 15:04"),
+				entry.Username,
+				entry.Command,
+				strings.Join(entry.Args, " "),
+				entry.Outcome)
+
+			if len(output)+len(line) > 1900 {
+				output += "... use `audit csv` for the rest"
+				break
+			}
+
+			output += line
+		}
+
+		commandList.send(channelID, output)
+	}
+}
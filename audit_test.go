@@ -0,0 +1,118 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAudit(t *testing.T) {
+	now := time.Now()
+
+	entries := []auditEntry{
+		{Time: now.Add(-30 * 24 * time.Hour), ChannelID: testChannel, UserID: "1", Username: "alice", Command: "clear", Args: []string{"50"}, Outcome: outcomeOK, Reply: "removed 50 messages"},
+		{Time: now.Add(-2 * time.Hour), ChannelID: testChannel, UserID: "2", Username: "bob", Command: "grab", Args: []string{"3"}, Outcome: outcomeError, Reply: "pick a release between 1 and 2"},
+		{Time: now.Add(-time.Hour), ChannelID: testChannel, UserID: "1", Username: "alice", Command: "add", Args: []string{"movie", "400535"}, Outcome: outcomeOK, Reply: "successfully added `Sicario: Day of the Soldado (2018)`"},
+	}
+
+	recorded := func(h *harness) {
+		for _, entry := range entries {
+			if err := auditTrail.record(entry); err != nil {
+				h.t.Fatalf("record audit entry: %v", err)
+			}
+		}
+	}
+
+	line := func(entry auditEntry) string {
+		return "`" + entry.Time.Format("2006-01-02 15:04") + "` " + entry.Username +
+			" `" + entry.Command + " " + strings.Join(entry.Args, " ") + "` - " + entry.Outcome + "\n"
+	}
+
+	runCommandCases(t, "audit", []commandCase{
+		{
+			name:  "last week",
+			setup: recorded,
+			want:  []string{"2 audited commands in the last 7 days:\n" + line(entries[2]) + line(entries[1])},
+		},
+		{
+			name:  "user",
+			args:  []string{"<@!1>"},
+			setup: recorded,
+			want:  []string{"1 audited commands in the last 7 days by `1`:\n" + line(entries[2])},
+		},
+		{
+			name: "raw user id",
+			args: []string{"123456789012345678"},
+			setup: func(h *harness) {
+				recorded(h)
+
+				if err := auditTrail.record(auditEntry{Time: now.Add(-time.Minute), ChannelID: testChannel, UserID: "123456789012345678", Username: "carol", Command: "defaults", Args: []string{"show"}, Outcome: outcomeOK}); err != nil {
+					h.t.Fatalf("record audit entry: %v", err)
+				}
+			},
+			want: []string{"1 audited commands in the last 7 days by `123456789012345678`:\n" +
+				"`" + now.Add(-time.Minute).Format("2006-01-02 15:04") + "` carol `defaults show` - ok\n"},
+		},
+		{
+			name:  "days",
+			args:  []string{"60"},
+			setup: recorded,
+			want:  []string{"3 audited commands in the last 60 days:\n" + line(entries[2]) + line(entries[1]) + line(entries[0])},
+		},
+		{
+			name:  "most days",
+			args:  []string{"3650", "<@1>"},
+			setup: recorded,
+			want:  []string{"2 audited commands in the last 3650 days by `1`:\n" + line(entries[2]) + line(entries[0])},
+		},
+		{
+			name: "nothing recorded",
+			want: []string{"0 audited commands in the last 7 days:\n"},
+		},
+		{
+			name:  "csv",
+			args:  []string{"csv", "bob"},
+			setup: recorded,
+			want:  []string{"1 audited commands in the last 7 days by `bob`"},
+			check: func(t *testing.T, h *harness) {
+				file, ok := h.chat.file(testChannel)
+
+				if !ok || file.file != "shart-audit.csv" {
+					t.Fatalf("uploaded %+v, want shart-audit.csv", file)
+				}
+
+				want := "time,guild_id,channel_id,user_id,username,command,args,outcome,reply\n" +
+					entries[1].Time.UTC().Format(time.RFC3339) + ",," + testChannel + ",2,bob,grab,3,error,pick a release between 1 and 2\n"
+
+				if file.fileBody != want {
+					t.Errorf("csv is\n%s\nwant\n%s", file.fileBody, want)
+				}
+			},
+		},
+	})
+}
+
+func TestIsAudited(t *testing.T) {
+	cases := []struct {
+		cmd  string
+		args []string
+		want bool
+	}{
+		{"add", []string{"movie", "273481"}, true},
+		{"clear", nil, true},
+		{"episode", []string{"81189", "S02E05"}, false},
+		{"episode", []string{"search", "81189", "S02E05"}, true},
+		{"season", []string{"search", "81189", "S02"}, true},
+		{"wanted", []string{"movie"}, false},
+		{"wanted", []string{"search", "movie"}, true},
+		{"search", []string{"movie", "sicario"}, false},
+		{"defaults", []string{"show"}, false},
+		{"defaults", []string{"show", "--type", "anime"}, true},
+	}
+
+	for _, c := range cases {
+		if got := isAudited(c.cmd, c.args); got != c.want {
+			t.Errorf("isAudited(%q, %q) = %v, want %v", c.cmd, c.args, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	radarr "github.com/jrudio/go-radarr-client"
+	sonarr "github.com/jrudio/go-sonarr-client"
+)
+
+// backend.go lets shart talk to both the old v2 api and the v3 api radarr and sonarr use now.
+// The go clients only know v2 so v3 servers are reached through arrAPI instead
+
+// movieBackend is everything shart asks of radarr
+type movieBackend interface {
+	Search(title string) ([]radarr.Movie, error)
+	GetMovie(tmdbID int) (radarr.Movie, error)
+	GetMovies(options radarr.GetMovieOptions) ([]radarr.Movie, error)
+	AddMovie(movie radarr.Movie) []error
+	DiscoverMovies() ([]radarr.Movie, error)
+	GetProfiles() ([]radarr.Profile, error)
+	GetRootFolders() ([]radarr.RootFolder, error)
+}
+
+// showBackend is everything shart asks of sonarr
+type showBackend interface {
+	Search(title string) ([]sonarr.SearchResults, error)
+	GetSeriesFromTVDB(tvdbID int) (*sonarr.Series, error)
+	GetAllSeries() ([]sonarr.Series, error)
+	GetEpisodes(seriesID int) ([]sonarr.Episode, error)
+	GetEpisodeFiles(seriesID int) ([]sonarr.EpisodeFile, error)
+	GetProfiles() ([]sonarr.Profile, error)
+	GetRootFolders() ([]sonarr.RootFolder, error)
+}
+
+// useDetectedAPIs switches backends whose startup check found a v3 or later
+// server over to the v3 api. Everything else stays on v2
+func useDetectedAPIs(services clients, checks []backendCheck) clients {
+	passed := map[string]backendCheck{}
+
+	for _, check := range checks {
+		if check.err == nil && check.major >= 3 {
+			passed[check.name] = check
+		}
+	}
+
+	for _, backend := range services.mediaBackends() {
+		check, ok := passed[backend.Name()]
+
+		if !ok {
+			continue
+		}
+
+		services = backend.UseV3(services)
+
+		logger.Info("using the v3 api", "backend", check.name, "version", check.status.Version)
+	}
+
+	return services
+}
+
+func (backend radarrBackend) UseV3(services clients) clients {
+	services.radarrAPI = services.radarrAPI.withRoot(v3APIRoot)
+	services.radarr = radarrV3{api: services.radarrAPI}
+
+	return services
+}
+
+func (backend sonarrBackend) UseV3(services clients) clients {
+	services.sonarrAPI = services.sonarrAPI.withRoot(v3APIRoot)
+	services.sonarr = sonarrV3{api: services.sonarrAPI}
+
+	return services
+}
+
+// v3Profile is a quality profile from the v3 api. Its cutoff is an id rather
+// than an object so it can't be decoded straight into the client's Profile
+type v3Profile struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// radarrV3Movie is a movie as the v3 api sends it, tags are ids instead of strings
+type radarrV3Movie struct {
+	radarr.Movie
+	Tags []int `json:"tags"`
+}
+
+func newRadarrV3Movie(movie radarr.Movie) radarrV3Movie {
+	v3Movie := radarrV3Movie{Movie: movie}
+
+	for _, tag := range movie.Tags {
+		if id, err := strconv.Atoi(tag); err == nil {
+			v3Movie.Tags = append(v3Movie.Tags, id)
+		}
+	}
+
+	return v3Movie
+}
+
+// movie converts back to the client's movie
+func (v3Movie radarrV3Movie) movie() radarr.Movie {
+	movie := v3Movie.Movie
+
+	movie.Tags = nil
+
+	for _, id := range v3Movie.Tags {
+		movie.Tags = append(movie.Tags, strconv.Itoa(id))
+	}
+
+	// v3 dropped downloaded in favour of hasFile
+	movie.Downloaded = movie.HasFile
+
+	return movie
+}
+
+func radarrV3Movies(v3Movies []radarrV3Movie) []radarr.Movie {
+	movies := make([]radarr.Movie, 0, len(v3Movies))
+
+	for _, movie := range v3Movies {
+		movies = append(movies, movie.movie())
+	}
+
+	return movies
+}
+
+// radarrV3 talks to radarr v3 and later
+type radarrV3 struct {
+	api arrAPI
+}
+
+func (backend radarrV3) Search(title string) ([]radarr.Movie, error) {
+	var movies []radarrV3Movie
+
+	err := backend.api.get("/movie/lookup", url.Values{"term": {title}}, &movies)
+
+	return radarrV3Movies(movies), err
+}
+
+func (backend radarrV3) GetMovie(tmdbID int) (radarr.Movie, error) {
+	var movie radarrV3Movie
+
+	err := backend.api.get("/movie/lookup/tmdb", url.Values{"tmdbId": {strconv.Itoa(tmdbID)}}, &movie)
+
+	return movie.movie(), err
+}
+
+// GetMovies pages and filters the library here since v3 always returns all of it
+func (backend radarrV3) GetMovies(options radarr.GetMovieOptions) ([]radarr.Movie, error) {
+	var v3Movies []radarrV3Movie
+
+	if err := backend.api.get("/movie", nil, &v3Movies); err != nil {
+		return nil, err
+	}
+
+	var movies []radarr.Movie
+
+	for _, movie := range radarrV3Movies(v3Movies) {
+		if movieMatches(movie, options.FilterKey, options.FilterValue) {
+			movies = append(movies, movie)
+		}
+	}
+
+	sort.SliceStable(movies, func(i, j int) bool {
+		if options.SortDir == "desc" {
+			return movies[i].SortTitle > movies[j].SortTitle
+		}
+
+		return movies[i].SortTitle < movies[j].SortTitle
+	})
+
+	return pageMovies(movies, options.Page, options.PageSize), nil
+}
+
+// movieMatches applies one of the v2 api's equality filters to a movie
+func movieMatches(movie radarr.Movie, key, value string) bool {
+	switch key {
+	case "monitored":
+		return strconv.FormatBool(movie.Monitored) == value
+	case "downloaded":
+		return strconv.FormatBool(movie.HasFile) == value
+	case "status":
+		return strings.EqualFold(movie.Status, value)
+	}
+
+	return true
+}
+
+// pageMovies returns one page of movies, a page size of -1 returns them all
+func pageMovies(movies []radarr.Movie, page, pageSize string) []radarr.Movie {
+	size, err := strconv.Atoi(pageSize)
+
+	if err != nil || size < 1 {
+		return movies
+	}
+
+	number, err := strconv.Atoi(page)
+
+	if err != nil || number < 1 {
+		number = 1
+	}
+
+	start := (number - 1) * size
+
+	if start >= len(movies) {
+		return nil
+	}
+
+	end := start + size
+
+	if end > len(movies) {
+		end = len(movies)
+	}
+
+	return movies[start:end]
+}
+
+// AddMovie keeps the client's ErrorMovieExists so callers can tell a duplicate apart
+func (backend radarrV3) AddMovie(movie radarr.Movie) []error {
+	var added radarrV3Movie
+
+	err := backend.api.post("/movie", newRadarrV3Movie(movie), &added)
+
+	if err == nil {
+		return nil
+	}
+
+	if strings.Contains(err.Error(), radarr.ErrorMovieExists.Error()) {
+		return []error{radarr.ErrorMovieExists}
+	}
+
+	return []error{err}
+}
+
+// DiscoverMovies returns the movies radarr's import lists and tmdb recommend
+func (backend radarrV3) DiscoverMovies() ([]radarr.Movie, error) {
+	var movies []radarrV3Movie
+
+	err := backend.api.get("/importlist/movie", url.Values{"includeRecommendations": {"true"}}, &movies)
+
+	return radarrV3Movies(movies), err
+}
+
+func (backend radarrV3) GetProfiles() ([]radarr.Profile, error) {
+	var v3Profiles []v3Profile
+
+	if err := backend.api.get("/qualityprofile", nil, &v3Profiles); err != nil {
+		return nil, err
+	}
+
+	var profiles []radarr.Profile
+
+	for _, profile := range v3Profiles {
+		profiles = append(profiles, radarr.Profile{ID: profile.ID, Name: profile.Name})
+	}
+
+	return profiles, nil
+}
+
+func (backend radarrV3) GetRootFolders() ([]radarr.RootFolder, error) {
+	var folders []radarr.RootFolder
+
+	err := backend.api.get("/rootfolder", nil, &folders)
+
+	return folders, err
+}
+
+// sonarrV2 is the go client for sonarr v2. The client asks for series, episodes
+// and episode files outside of /api, so those are fetched through arrAPI instead
+type sonarrV2 struct {
+	*sonarr.Sonarr
+	api arrAPI
+}
+
+func (backend sonarrV2) GetAllSeries() ([]sonarr.Series, error) {
+	var shows []sonarr.Series
+
+	err := backend.api.get("/series", nil, &shows)
+
+	return shows, err
+}
+
+func (backend sonarrV2) GetEpisodes(seriesID int) ([]sonarr.Episode, error) {
+	var episodes []sonarr.Episode
+
+	err := backend.api.get("/episode", url.Values{"seriesId": {strconv.Itoa(seriesID)}}, &episodes)
+
+	return episodes, err
+}
+
+func (backend sonarrV2) GetEpisodeFiles(seriesID int) ([]sonarr.EpisodeFile, error) {
+	var files []sonarr.EpisodeFile
+
+	err := backend.api.get("/episodefile", url.Values{"seriesId": {strconv.Itoa(seriesID)}}, &files)
+
+	return files, err
+}
+
+// sonarrV3SearchResult is a lookup result as the v3 api sends it, tags are ids instead of strings
+type sonarrV3SearchResult struct {
+	sonarr.SearchResults
+	Tags []int `json:"tags"`
+}
+
+// sonarrV3 talks to sonarr v3 and later
+type sonarrV3 struct {
+	api arrAPI
+}
+
+func (backend sonarrV3) Search(title string) ([]sonarr.SearchResults, error) {
+	var v3Results []sonarrV3SearchResult
+
+	if err := backend.api.get("/series/lookup", url.Values{"term": {title}}, &v3Results); err != nil {
+		return nil, err
+	}
+
+	results := make([]sonarr.SearchResults, 0, len(v3Results))
+
+	for _, result := range v3Results {
+		show := result.SearchResults
+
+		show.Tags = nil
+
+		for _, id := range result.Tags {
+			show.Tags = append(show.Tags, strconv.Itoa(id))
+		}
+
+		results = append(results, show)
+	}
+
+	return results, nil
+}
+
+func (backend sonarrV3) GetSeriesFromTVDB(tvdbID int) (*sonarr.Series, error) {
+	if tvdbID <= 0 {
+		return nil, errors.New("tvdb id must be a positive integer")
+	}
+
+	var shows []sonarr.Series
+
+	if err := backend.api.get("/series/lookup", url.Values{"term": {fmt.Sprintf("tvdb:%d", tvdbID)}}, &shows); err != nil {
+		return nil, err
+	}
+
+	for _, show := range shows {
+		if show.TvdbID == tvdbID {
+			return &show, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid series id: %d", tvdbID)
+}
+
+// sonarrV3Series is a series as the v3 api sends it, its counts moved into statistics
+type sonarrV3Series struct {
+	sonarr.Series
+	Statistics struct {
+		EpisodeFileCount  int `json:"episodeFileCount"`
+		EpisodeCount      int `json:"episodeCount"`
+		TotalEpisodeCount int `json:"totalEpisodeCount"`
+		SizeOnDisk        int `json:"sizeOnDisk"`
+	} `json:"statistics"`
+}
+
+func (backend sonarrV3) GetAllSeries() ([]sonarr.Series, error) {
+	var v3Shows []sonarrV3Series
+
+	if err := backend.api.get("/series", nil, &v3Shows); err != nil {
+		return nil, err
+	}
+
+	shows := make([]sonarr.Series, 0, len(v3Shows))
+
+	for _, v3Show := range v3Shows {
+		show := v3Show.Series
+
+		show.EpisodeFileCount = v3Show.Statistics.EpisodeFileCount
+		show.EpisodeCount = v3Show.Statistics.EpisodeCount
+		show.TotalEpisodeCount = v3Show.Statistics.TotalEpisodeCount
+		show.SizeOnDisk = v3Show.Statistics.SizeOnDisk
+
+		shows = append(shows, show)
+	}
+
+	return shows, nil
+}
+
+func (backend sonarrV3) GetEpisodes(seriesID int) ([]sonarr.Episode, error) {
+	var episodes []sonarr.Episode
+
+	err := backend.api.get("/episode", url.Values{"seriesId": {strconv.Itoa(seriesID)}}, &episodes)
+
+	return episodes, err
+}
+
+func (backend sonarrV3) GetEpisodeFiles(seriesID int) ([]sonarr.EpisodeFile, error) {
+	var files []sonarr.EpisodeFile
+
+	err := backend.api.get("/episodefile", url.Values{"seriesId": {strconv.Itoa(seriesID)}}, &files)
+
+	return files, err
+}
+
+func (backend sonarrV3) GetProfiles() ([]sonarr.Profile, error) {
+	var v3Profiles []v3Profile
+
+	if err := backend.api.get("/qualityprofile", nil, &v3Profiles); err != nil {
+		return nil, err
+	}
+
+	var profiles []sonarr.Profile
+
+	for _, profile := range v3Profiles {
+		profiles = append(profiles, sonarr.Profile{ID: profile.ID, Name: profile.Name})
+	}
+
+	return profiles, nil
+}
+
+func (backend sonarrV3) GetRootFolders() ([]sonarr.RootFolder, error) {
+	var folders []sonarr.RootFolder
+
+	err := backend.api.get("/rootfolder", nil, &folders)
+
+	return folders, err
+}
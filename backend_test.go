@@ -0,0 +1,112 @@
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestUseDetectedAPIs(t *testing.T) {
+	radarrServer := newFakeArr(t, "radarr", 3)
+	sonarrServer := newFakeArr(t, "sonarr", 3)
+
+	// sonarr v2 only answers the old api
+	sonarrServer.respond("GET /api/v3/system/status", 404, "")
+	sonarrServer.respond("GET /api/system/status", 200, `{"version":"2.0.0.5344","branch":"master"}`)
+
+	services, err := initializeClients(serviceCredentials{
+		radarr: radarrCredentials{url: radarrServer.server.URL, apiKey: "radarr-key"},
+		sonarr: sonarrCredentials{url: sonarrServer.server.URL, apiKey: "sonarr-key"},
+	})
+
+	if err != nil {
+		t.Fatalf("initialize clients: %v", err)
+	}
+
+	checks := checkBackends(services)
+
+	for i, want := range []int{3, 2} {
+		if checks[i].err != nil || checks[i].major != want {
+			t.Errorf("%s check found v%d (%v), want v%d", checks[i].name, checks[i].major, checks[i].err, want)
+		}
+	}
+
+	services = withMediaBackends(useDetectedAPIs(services, checks))
+
+	if _, ok := services.radarr.(radarrV3); !ok {
+		t.Errorf("radarr uses %T, want the v3 api", services.radarr)
+	}
+
+	if _, ok := services.sonarr.(sonarrV3); ok {
+		t.Errorf("sonarr v2 uses the v3 api")
+	}
+
+	for mediaType, want := range map[string]string{"movie": v3APIRoot, "show": legacyAPIRoot} {
+		backend, ok := services.mediaBackend(mediaType)
+
+		if !ok {
+			t.Fatalf("no backend for %s", mediaType)
+		}
+
+		if root := backend.API().root; root != want {
+			t.Errorf("%s backend calls %s, want %s", backend.Name(), root, want)
+		}
+	}
+}
+
+func TestRecheckEnablesRecoveredBackend(t *testing.T) {
+	radarrServer := newFakeArr(t, "radarr", 3)
+	sonarrServer := newFakeArr(t, "sonarr", 3)
+
+	// radarr is down while shart starts
+	radarrServer.respond("GET /api/v3/system/status", 502, "")
+	radarrServer.respond("GET /api/system/status", 502, "")
+
+	services, err := initializeClients(serviceCredentials{
+		radarr: radarrCredentials{url: radarrServer.server.URL, apiKey: "radarr-key"},
+		sonarr: sonarrCredentials{url: sonarrServer.server.URL, apiKey: "sonarr-key"},
+	})
+
+	if err != nil {
+		t.Fatalf("initialize clients: %v", err)
+	}
+
+	backends := newBackendState(services, checkBackends(services))
+
+	want := "`search` is disabled because radarr could not be reached: 502 Bad Gateway"
+
+	if got := backends.backendUnavailable("search", []string{"movie", "sicario"}); got != want {
+		t.Errorf("unavailable reason is %q, want %q", got, want)
+	}
+
+	if passed := backends.recheck(); len(passed) != 0 {
+		t.Errorf("recheck passed %d backends while radarr is still down", len(passed))
+	}
+
+	status, err := ioutil.ReadFile("testdata/radarr/system_status.json")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	radarrServer.respond("GET /api/v3/system/status", 200, string(status))
+
+	passed := backends.recheck()
+
+	if len(passed) != 1 || passed[0].name != "radarr" {
+		t.Fatalf("recheck passed %+v, want radarr", passed)
+	}
+
+	if got := backends.backendUnavailable("search", []string{"movie", "sicario"}); got != "" {
+		t.Errorf("radarr is still disabled: %s", got)
+	}
+
+	if _, ok := backends.clients().radarr.(radarrV3); !ok {
+		t.Errorf("recovered radarr uses %T, want the v3 api", backends.clients().radarr)
+	}
+
+	backend, ok := backends.clients().mediaBackend("movie")
+
+	if !ok || backend.API().root != v3APIRoot {
+		t.Errorf("recovered movie backend doesn't call the v3 api")
+	}
+}
@@ -2,39 +2,92 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
-	radarr "github.com/jrudio/go-radarr-client"
-	sonarr "github.com/jrudio/go-sonarr-client"
 )
 
+// chatTransport is the part of discord commands talk to
+type chatTransport interface {
+	// BotID is the user id shart is logged in as
+	BotID() string
+	ChannelMessageSend(channelID, content string) (*discordgo.Message, error)
+	ChannelFileSendWithMessage(channelID, content, name string, r io.Reader) (*discordgo.Message, error)
+	ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string) ([]*discordgo.Message, error)
+	ChannelMessagesBulkDelete(channelID string, messages []string) error
+	ChannelMessageDelete(channelID, messageID string) error
+}
+
+// discordSession is a chatTransport backed by a discord connection
+type discordSession struct {
+	*discordgo.Session
+}
+
+func (session discordSession) BotID() string {
+	return session.State.User.ID
+}
+
+// commandBuilder makes a command that replies through commandList
+type commandBuilder func(commandList d, services clients) func(channelID string, args ...string)
+
 type d struct {
-	cmds    map[string]func(channelID string, args ...string)
-	discord *discordgo.Session
+	cmds        map[string]commandBuilder
+	discord     chatTransport
+	backends    *backendState
+	invocations *invocations
+	// messageID is the message the running command answers, empty outside track
+	messageID string
 }
 
-func newDiscord(session *discordgo.Session) d {
+func newDiscord(transport chatTransport) d {
 	return d{
-		cmds:    map[string]func(channelID string, args ...string){},
-		discord: session,
+		cmds:        map[string]commandBuilder{},
+		discord:     transport,
+		invocations: newInvocations(),
 	}
 }
 
-func (discord d) addCommand(cmd string, fn func(channelID string, args ...string)) {
-	discord.cmds[cmd] = fn
+func (discord d) addCommand(cmd string, build commandBuilder) {
+	discord.cmds[cmd] = build
 }
 
-func (discord d) execute(channelID, cmd string, args ...string) {
-	if fn, ok := discord.cmds[cmd]; ok {
-		fn(channelID, args...)
-	} else {
-		if isVerbose {
-			fmt.Printf("invalid command: %s\n", cmd)
-		}
+// track runs fn to answer a message and returns everything fn's commandList
+// sent while it ran. Commands answering other messages in the same channel
+// aren't held up by it
+func (discord d) track(messageID string, fn func(commandList commands)) invocation {
+	discord.invocations.begin(messageID)
+
+	discord.messageID = messageID
+
+	fn(discord)
+
+	return discord.invocations.end(messageID)
+}
+
+// execute runs cmd and returns what it did
+func (discord d) execute(channelID, cmd string, args ...string) invocation {
+	build, ok := discord.cmds[cmd]
+
+	if !ok {
+		logger.Debug("invalid command", "channel", channelID, "command", cmd)
+		return invocation{failed: true}
 	}
+
+	build(discord, discord.backends.clients())(channelID, args...)
+
+	current, ok := discord.invocations.ran(discord.messageID, cmd)
+
+	if !ok {
+		// not running under track so there's nothing to report
+		current = invocation{command: cmd}
+	}
+
+	commandsTotal.inc(cmd, current.outcome())
+
+	return current
 }
 
 func (discord d) isValid(cmd string) bool {
@@ -50,13 +103,18 @@ func (discord d) showHelp(channelID string) {
 		msg += "`" + key + "`\n"
 	}
 
-	_, err := discord.discord.ChannelMessageSend(channelID, msg)
+	discord.send(channelID, msg)
+}
 
-	if err != nil {
-		fmt.Printf("failed to send command list to channel %s: %v\n",
-			channelID,
-			err)
+// invalidCommand builds the reply for a command we don't recognize
+func (discord d) invalidCommand(cmd string) string {
+	output := fmt.Sprintf("invalid command: `%s`", cmd)
+
+	if match := closestMatch(cmd, discord.getCommands()); match != "" {
+		output += fmt.Sprintf(", did you mean `%s`?", match)
 	}
+
+	return output
 }
 
 func (discord d) getCommands() []string {
@@ -77,63 +135,257 @@ func (discord d) getCommands() []string {
 	return cmds
 }
 
+// showError replies with msg and marks the running command as failed
 func (discord d) showError(channelID, msg string) {
-	_, err := discord.discord.ChannelMessageSend(channelID, msg)
+	discord.invocations.fail(discord.messageID)
+	discord.send(channelID, msg)
+}
 
-	if err != nil && isVerbose {
-		fmt.Printf("send message failed: %v", err)
+// send replies to a channel, logging and counting messages discord rejects
+func (discord d) send(channelID, msg string) error {
+	message, err := discord.discord.ChannelMessageSend(channelID, msg)
+
+	if err != nil {
+		discordSendFailures.inc()
+		logger.Warn("send message failed", "channel", channelID, "error", err)
+		return err
 	}
+
+	discord.invocations.replied(discord.messageID, message)
+
+	return nil
 }
 
-func clearMessages(commandList d, services clients) func(channelID string, args ...string) {
-	return func(channelID string, args ...string) {
-		argCount := len(args)
-		messageLimit := 0
+// notify posts to a channel outside of any command, e.g. when something
+// running in the background finishes, so it isn't attributed to whatever
+// command happens to be running in the channel
+func (discord d) notify(channelID, msg string) error {
+	if _, err := discord.discord.ChannelMessageSend(channelID, msg); err != nil {
+		discordSendFailures.inc()
+		logger.Warn("send notification failed", "channel", channelID, "error", err)
+		return err
+	}
 
-		if argCount > 0 {
-			// make sure arg is an int
-			limit, err := strconv.Atoi(args[0])
+	return nil
+}
+
+// sendFile uploads a file to a channel with an accompanying message
+func (discord d) sendFile(channelID, msg, name string, r io.Reader) error {
+	message, err := discord.discord.ChannelFileSendWithMessage(channelID, msg, name, r)
+
+	if err != nil {
+		discordSendFailures.inc()
+		logger.Warn("send file failed", "channel", channelID, "error", err)
+		return err
+	}
+
+	discord.invocations.replied(discord.messageID, message)
+
+	return nil
+}
+
+const (
+	// defaultClearLimit is how many messages `clear` removes without a count
+	defaultClearLimit = 50
+	// maxClearLimit keeps a single `clear` from paging through a whole channel
+	maxClearLimit = 1000
+	// bulkDeleteMaxAge is how old a message can be for discord to bulk delete it
+	// it's a little under discord's 14 days so we don't race the cutoff
+	bulkDeleteMaxAge = 14*24*time.Hour - time.Hour
+)
+
+// clearOptions filter which messages `clear` removes
+type clearOptions struct {
+	limit int
+	// botOnly and commandsOnly are combined: either kind of message matches
+	botOnly      bool
+	commandsOnly bool
+	userID       string
+	beforeID     string
+}
+
+func parseClearArgs(args []string) (clearOptions, error) {
+	options := clearOptions{limit: defaultClearLimit}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch arg {
+		case "":
+			continue
+		case "--bot-only":
+			options.botOnly = true
+		case "--commands-only":
+			options.commandsOnly = true
+		case "--user", "--before":
+			if i+1 >= len(args) {
+				return options, fmt.Errorf("`%s` needs a value", arg)
+			}
+
+			i++
+
+			if arg == "--user" {
+				options.userID = mentionedUserID(args[i])
+			} else {
+				options.beforeID = args[i]
+			}
+		default:
+			limit, err := strconv.Atoi(arg)
 
 			if err != nil {
-				fmt.Printf("%v - clear command - channel id %s - failed because arg: %v\n",
-					time.Now().String(),
-					channelID,
-					err)
+				return options, fmt.Errorf("unknown option `%s`", arg)
+			}
 
-				return
+			if limit < 1 {
+				return options, fmt.Errorf("the number of messages to clear must be at least 1, got `%s`", arg)
+			}
+
+			if limit > maxClearLimit {
+				limit = maxClearLimit
 			}
 
-			messageLimit = limit
+			options.limit = limit
 		}
+	}
 
-		messages, err := commandList.discord.ChannelMessages(channelID, messageLimit, "", "", "")
+	return options, nil
+}
+
+// matches reports whether a message should be removed
+func (options clearOptions) matches(message *discordgo.Message, botID string) bool {
+	if message.Author == nil {
+		return false
+	}
+
+	if options.userID != "" && message.Author.ID != options.userID {
+		return false
+	}
+
+	if !options.botOnly && !options.commandsOnly {
+		return true
+	}
+
+	if options.botOnly && message.Author.ID == botID {
+		return true
+	}
+
+	return options.commandsOnly && strings.HasPrefix(message.Content, keyword)
+}
+
+// snowflakeTime returns when a discord id was created
+func snowflakeTime(id string) time.Time {
+	snowflake, err := strconv.ParseInt(id, 10, 64)
+
+	if err != nil {
+		return time.Time{}
+	}
+
+	const discordEpoch = 1420070400000
+
+	return time.Unix(0, ((snowflake>>22)+discordEpoch)*int64(time.Millisecond))
+}
+
+func clearMessages(commandList d, services clients) func(channelID string, args ...string) {
+	return func(channelID string, args ...string) {
+		// command: clear [n] [--bot-only] [--commands-only] [--user @someone] [--before message-id]
+		options, err := parseClearArgs(args)
 
 		if err != nil {
-			fmt.Printf("failed to retrieve message ids: %v\n", err)
+			commandList.showError(channelID, fmt.Sprintf("%v\n`clear [n] [--bot-only] [--commands-only] [--user @someone] [--before message-id]`", err))
 			return
 		}
 
-		messageIDs := make([]string, len(messages))
+		botID := commandList.discord.BotID()
+		beforeID := options.beforeID
+
+		var recent, old []string
+
+		// page through the channel's history until we have enough matching messages
+		// don't look at more than a few pages per message wanted in case the filters rarely match
+		for scanned := 0; len(recent)+len(old) < options.limit && scanned < options.limit*10; {
+			messages, err := commandList.discord.ChannelMessages(channelID, 100, beforeID, "", "")
+
+			if err != nil {
+				channelLogger(channelID).Error("failed to retrieve messages", "error", err)
+				commandList.showError(channelID, fmt.Sprintf("failed to retrieve messages: %v", err))
+				return
+			}
+
+			if len(messages) == 0 {
+				break
+			}
+
+			for _, message := range messages {
+				if len(recent)+len(old) >= options.limit {
+					break
+				}
+
+				if !options.matches(message, botID) {
+					continue
+				}
+
+				if time.Since(snowflakeTime(message.ID)) < bulkDeleteMaxAge {
+					recent = append(recent, message.ID)
+				} else {
+					old = append(old, message.ID)
+				}
+			}
+
+			scanned += len(messages)
+			beforeID = messages[len(messages)-1].ID
+		}
+
+		removed := 0
+
+		for start := 0; start < len(recent); start += 100 {
+			end := start + 100
 
-		for i, message := range messages {
-			messageIDs[i] = message.ID
+			if end > len(recent) {
+				end = len(recent)
+			}
+
+			if err := commandList.discord.ChannelMessagesBulkDelete(channelID, recent[start:end]); err != nil {
+				channelLogger(channelID).Error("failed to bulk delete messages", "error", err)
+				commandList.showError(channelID, fmt.Sprintf("removed %d messages before failing: %v", removed, err))
+				return
+			}
+
+			removed += end - start
 		}
 
-		if err := commandList.discord.ChannelMessagesBulkDelete(channelID, messageIDs); err != nil {
-			fmt.Printf("failed to delete messages: %v\n", err)
-			commandList.showError(channelID, err.Error())
+		// discord won't bulk delete messages older than 14 days so they go one at a time
+		for _, messageID := range old {
+			if err := commandList.discord.ChannelMessageDelete(channelID, messageID); err != nil {
+				channelLogger(channelID).Error("failed to delete message", "message", messageID, "error", err)
+				commandList.showError(channelID, fmt.Sprintf("removed %d messages before failing: %v", removed, err))
+				return
+			}
+
+			removed++
 		}
+
+		commandList.send(channelID, fmt.Sprintf("removed %d messages", removed))
 	}
 }
 
+// mediaBackendFor returns the backend for the media type a command was given,
+// replying with an error when there isn't one
+func mediaBackendFor(commandList d, services clients, channelID, mediaType string) (MediaBackend, bool) {
+	backend, ok := services.mediaBackend(mediaType)
+
+	if !ok {
+		commandList.showError(channelID, unknownMediaType(mediaType))
+	}
+
+	return backend, ok
+}
+
 func search(commandList d, services clients) func(channelID string, args ...string) {
 	return func(channelID string, args ...string) {
 		argCount := len(args)
 
 		// we must have at least 2 args: media type and the title
 		if argCount < 2 {
-			fmt.Printf("%s - channel id: %s - no args\n", time.Now().String(), channelID)
-
 			output := "search requires `<movie|show> <title>`\n"
 
 			commandList.showError(channelID, output)
@@ -142,7 +394,11 @@ func search(commandList d, services clients) func(channelID string, args ...stri
 
 		// we have to parse the first arg to know if we're dealing
 		// with a movie or a show type search
-		mediaType := args[0]
+		backend, ok := mediaBackendFor(commandList, services, channelID, resolveMediaType(args[0]))
+
+		if !ok {
+			return
+		}
 
 		// remove media type from args
 		args = args[1:argCount]
@@ -153,66 +409,45 @@ func search(commandList d, services clients) func(channelID string, args ...stri
 			return
 		}
 
-		switch mediaType {
-		case "movie":
-			title := strings.Join(args, " ")
+		title := strings.Join(args, " ")
 
-			results, err := services.radarr.Search(title)
+		results, err := backend.Search(parseMediaQuery(title))
 
-			if err != nil {
-				output := fmt.Sprintf("search failed: %v", err)
-				commandList.showError(channelID, output)
-				return
-			}
-
-			resultCount := len(results)
-			formattedResults := "No results found"
-
-			if resultCount > 0 {
-				formattedResults = "Here are your search results for `" + title + "`:\n"
-
-				for _, result := range results {
-					// can't display movie summary because of Discord's 2000 character limit
-					formattedResults += "- " + result.Title + " (" + strconv.Itoa(result.Year) + ") `" + strconv.Itoa(result.TmdbID) + "`\n"
-				}
-			}
+		if err != nil {
+			channelLogger(channelID).Error("search failed", "backend", backend.Name(), "error", err)
+			output := fmt.Sprintf("search failed: %v", err)
+			commandList.showError(channelID, output)
+			return
+		}
 
-			commandList.discord.ChannelMessageSend(channelID, formattedResults)
-		case "show":
-			title := strings.Join(args, " ")
+		resultCount := len(results)
+		formattedResults := "No results found"
 
-			results, err := services.sonarr.Search(title)
+		if resultCount > 0 {
+			// without the library we can still show results, just not their status
+			items, err := backend.Library()
 
 			if err != nil {
-				fmt.Printf("%v - channel id: %s - %v\n", time.Now().String(), channelID, err)
-				commandList.showError(channelID, err.Error())
-				return
+				channelLogger(channelID).Warn("fetch library failed", "backend", backend.Name(), "error", err)
 			}
 
-			resultCount := len(results)
-			formattedResults := "No results found"
+			library := libraryByID(items)
 
-			if resultCount > 0 {
-				formattedResults = "Here are your search results for `" + title + "`:\n"
+			formattedResults = "Here are your search results for `" + title + "`:\n"
 
-				for _, result := range results {
-					// can't display movie summary because of Discord's 2000 character limit
-					formattedResults += "- " + result.Title + " (" + strconv.Itoa(result.Year) + ") `" + strconv.Itoa(result.TvdbID) + "`\n"
-				}
-			}
+			for _, result := range results {
+				// can't display the summary because of Discord's 2000 character limit
+				formattedResults += "- " + result.title + " (" + strconv.Itoa(result.year) + ") `" + strconv.Itoa(result.id) + "`"
 
-			commandList.discord.ChannelMessageSend(channelID, formattedResults)
-		default:
-			// unknown type
-			output := "unknown media type: %s\n\tshould be one of `movie|show`\n"
-			output += "Here is a list of available commands: \n"
+				if item, ok := library[result.id]; ok {
+					formattedResults += " - " + item.status
+				}
 
-			for _, commandNames := range commandList.getCommands() {
-				output += "`" + commandNames + "`\n"
+				formattedResults += "\n"
 			}
-
-			commandList.showError(channelID, fmt.Sprintf(output, mediaType))
 		}
+
+		commandList.send(channelID, formattedResults)
 	}
 }
 
@@ -227,55 +462,27 @@ func showQualityProfiles(commandList d, services clients) func(channelID string,
 		}
 
 		// first arg should be 'movie' or 'show'
-		mediaType := args[0]
-
-		switch mediaType {
-		case "movie":
-			profiles, err := services.radarr.GetProfiles()
-
-			if err != nil {
-				errMsg := fmt.Sprintf("failed to fetch profiles from radarr: %v\n", err)
-				fmt.Printf(errMsg)
-				commandList.showError(channelID, errMsg)
-				return
-			}
-
-			output := "Here are the available quality profiles for radarr:\n"
-
-			for _, profile := range profiles {
-				output += fmt.Sprintf("\t`id: %d` %s\n", profile.ID, profile.Name)
-			}
+		backend, ok := mediaBackendFor(commandList, services, channelID, resolveMediaType(args[0]))
 
-			if _, err := commandList.discord.ChannelMessageSend(channelID, output); err != nil {
-				fmt.Printf("chan id: %s - %v\n", channelID, err)
-				return
-			}
-		case "show":
-			profiles, err := services.sonarr.GetProfiles()
-
-			if err != nil {
-				errMsg := fmt.Sprintf("failed to fetch profiles from sonarr: %v\n", err)
-				fmt.Printf(errMsg)
-				commandList.showError(channelID, errMsg)
-				return
-			}
+		if !ok {
+			return
+		}
 
-			output := "Here are the available quality profiles for sonarr:\n"
+		profiles, err := backend.Profiles()
 
-			for _, profile := range profiles {
-				output += fmt.Sprintf("\t`id: %d` %s\n", profile.ID, profile.Name)
-			}
+		if err != nil {
+			channelLogger(channelID).Error("failed to fetch profiles", "backend", backend.Name(), "error", err)
+			commandList.showError(channelID, err.Error())
+			return
+		}
 
-			if _, err := commandList.discord.ChannelMessageSend(channelID, output); err != nil {
-				fmt.Printf("chan id: %s - %v\n", channelID, err)
-				return
-			}
+		output := fmt.Sprintf("Here are the available quality profiles for %s:\n", backend.Name())
 
-		default:
-			errMsg := fmt.Sprintf("unknown media type: %s\n", mediaType)
-			fmt.Printf(errMsg)
-			commandList.showError(channelID, errMsg)
+		for _, profile := range profiles {
+			output += fmt.Sprintf("\t`id: %d` %s\n", profile.id, profile.name)
 		}
+
+		commandList.send(channelID, output)
 	}
 }
 
@@ -285,49 +492,33 @@ func setQualityProfile(commandList d, services clients) func(channelID string, a
 
 		// we should have 2 args
 		if argCount < 2 {
-			commandList.showError(channelID, "need more args: `movie|show` <quality-profile-id>")
+			commandList.showError(channelID, "need more args: `movie|show` <quality-profile-name|id>")
 			return
 		}
 
 		// first arg should be 'movie' or 'show'
-		mediaType := args[0]
-		qualityProfileID := args[1]
+		mediaType := resolveMediaType(args[0])
+		// profile names can have spaces: `Ultra HD`
+		nameOrID := strings.TrimSpace(strings.Join(args[1:], " "))
+
+		backend, ok := mediaBackendFor(commandList, services, channelID, mediaType)
 
-		// a profile quality id is required
-		if qualityProfileID == "" {
-			commandList.showError(channelID, "a quality profile id is required\n get id via command `quality`")
+		if !ok {
 			return
 		}
 
-		profileID, err := strconv.Atoi(qualityProfileID)
+		profile, err := resolveProfile(backend, nameOrID)
 
 		if err != nil {
-			output := fmt.Sprintf("failed to convert profile quality id to int: %v", err)
-			fmt.Printf("channel id: %s - %s\n", channelID, output)
-			commandList.showError(channelID, output)
+			channelLogger(channelID).Info("could not resolve quality profile", "backend", backend.Name(), "error", err)
+			commandList.showError(channelID, err.Error())
 			return
 		}
 
-		// radarr and sonarr do not have an id of 0
-		if profileID == 0 {
-			commandList.showError(channelID, "please enter a valid quality profile id\n use `quality` to find valid id")
-			return
-		}
+		*backend.Settings().qualityProfileID = profile.id
 
-		switch mediaType {
-		case "movie":
-			defaultRadarrQualityID = profileID
-			output := "successfully set movie quality to `%d`"
-			commandList.discord.ChannelMessageSend(channelID, fmt.Sprintf(output, defaultRadarrQualityID))
-		case "show":
-			defaultSonarrQualityID = profileID
-			output := "successfully set series quality to `%d`"
-			commandList.discord.ChannelMessageSend(channelID, fmt.Sprintf(output, defaultSonarrQualityID))
-		default:
-			output := "unknown media type: %s\n\tshould be one of `movie|show`"
-			commandList.discord.ChannelMessageSend(channelID, fmt.Sprintf(output, mediaType))
-			return
-		}
+		output := "successfully set %s quality to `%s` (`%d`)"
+		commandList.send(channelID, fmt.Sprintf(output, mediaType, profile.name, profile.id))
 	}
 }
 
@@ -342,54 +533,40 @@ func showRootFolders(commandList d, services clients) func(channelID string, arg
 		}
 
 		// first arg should be 'movie' or 'show'
-		mediaType := args[0]
+		backend, ok := mediaBackendFor(commandList, services, channelID, resolveMediaType(args[0]))
 
-		switch mediaType {
-		case "movie":
-			folders, err := services.radarr.GetRootFolders()
+		if !ok {
+			return
+		}
 
-			if err != nil {
-				errMsg := fmt.Sprintf("failed to fetch folders from radarr: %v\n", err)
-				fmt.Printf(errMsg)
-				commandList.showError(channelID, errMsg)
-				return
-			}
+		folders, err := backend.RootFolders()
 
-			output := "Here are the available root folders for radarr:\n"
+		if err != nil {
+			channelLogger(channelID).Error("failed to fetch folders", "backend", backend.Name(), "error", err)
+			commandList.showError(channelID, err.Error())
+			return
+		}
 
-			for _, folder := range folders {
-				output += fmt.Sprintf("\t`id: %d` - %s\n", folder.ID, folder.Path)
-			}
+		// the size of the disk each folder is on is only known from the disk space endpoint
+		disks, err := fetchDiskSpace(backend.API())
 
-			if _, err := commandList.discord.ChannelMessageSend(channelID, output); err != nil {
-				fmt.Printf("chan id: %s - %v\n", channelID, err)
-				return
-			}
-		case "show":
-			folders, err := services.sonarr.GetRootFolders()
+		if err != nil {
+			channelLogger(channelID).Warn("failed to fetch disk space", "backend", backend.Name(), "error", err)
+		}
 
-			if err != nil {
-				errMsg := fmt.Sprintf("failed to fetch folders from sonarr: %v\n", err)
-				fmt.Printf(errMsg)
-				commandList.showError(channelID, errMsg)
-				return
-			}
+		output := fmt.Sprintf("Here are the available root folders for %s:\n", backend.Name())
 
-			output := "Here are the available root folders for sonarr:\n"
+		for _, folder := range folders {
+			output += fmt.Sprintf("\t`id: %d` - %s %s free", folder.id, folder.path, formatBytes(folder.freeSpace))
 
-			for _, folder := range folders {
-				output += fmt.Sprintf("\t`id: %d` - %s\n", folder.ID, folder.Path)
+			if disk, ok := diskFor(folder.path, disks); ok && disk.TotalSpace > 0 {
+				output += " of " + formatBytes(disk.TotalSpace)
 			}
 
-			if _, err := commandList.discord.ChannelMessageSend(channelID, output); err != nil {
-				fmt.Printf("chan id: %s - %v\n", channelID, err)
-				return
-			}
-		default:
-			errMsg := fmt.Sprintf("unknown media type: %s\n", mediaType)
-			fmt.Printf(errMsg)
-			commandList.showError(channelID, errMsg)
+			output += "\n"
 		}
+
+		commandList.send(channelID, output)
 	}
 }
 
@@ -404,245 +581,141 @@ func setRootFolder(commandList d, services clients) func(channelID string, args
 		}
 
 		// first arg should be 'movie' or 'show'
-		mediaType := args[0]
-		folderPathOrID := args[1]
+		backend, ok := mediaBackendFor(commandList, services, channelID, resolveMediaType(args[0]))
 
-		// a path or path id is required
-		if folderPathOrID == "" {
-			commandList.showError(channelID, "a folder path or path id is required\n get id via command `folders`")
+		if !ok {
 			return
 		}
 
-		switch mediaType {
-		case "movie":
-
-			if strings.HasPrefix(folderPathOrID, "/") {
-				defaultRadarrPath = folderPathOrID
-			} else {
-				pathID, err := strconv.Atoi(folderPathOrID)
-
-				if err != nil {
-					output := fmt.Sprintf("failed to convert path id to int: %v", err)
-					fmt.Printf("channel id: %s - %s\n", channelID, output)
-					commandList.showError(channelID, output)
-					return
-				}
-
-				folders, err := services.radarr.GetRootFolders()
-
-				if err != nil {
-					output := fmt.Sprintf("fetch radarr root folders failed: %v", err)
-					fmt.Printf("channel id: %s - %s\n", channelID, output)
-					commandList.showError(channelID, output)
-					return
-				}
-
-				for _, folder := range folders {
-					if folder.ID == pathID {
-						defaultRadarrPath = folder.Path
-					}
-				}
-
-				if defaultRadarrPath == "" {
-					output := "could not find stored path via id: `%s`"
-					commandList.showError(channelID, fmt.Sprintf(output, folderPathOrID))
-					return
-				}
-			}
+		folderPathOrID := strings.TrimSpace(strings.Join(args[1:], " "))
 
-			output := "successfully set root folder to `%s`"
-			commandList.discord.ChannelMessageSend(channelID, fmt.Sprintf(output, defaultRadarrPath))
-		case "show":
-			if strings.HasPrefix(folderPathOrID, "/") {
-				defaultSonarrPath = folderPathOrID
-			} else {
-				pathID, err := strconv.Atoi(folderPathOrID)
-
-				if err != nil {
-					output := fmt.Sprintf("failed to convert path id to int: %v", err)
-					fmt.Printf("channel id: %s - %s\n", channelID, output)
-					commandList.showError(channelID, output)
-					return
-				}
-
-				folders, err := services.sonarr.GetRootFolders()
-
-				if err != nil {
-					output := fmt.Sprintf("fetch sonarr root folders failed: %v", err)
-					fmt.Printf("channel id: %s - %s\n", channelID, output)
-					commandList.showError(channelID, output)
-					return
-				}
+		folder, err := resolveRootFolder(backend, folderPathOrID)
 
-				for _, folder := range folders {
-					if folder.ID == pathID {
-						defaultSonarrPath = folder.Path
-					}
-				}
-
-				if defaultSonarrPath == "" {
-					output := "could not find stored path via id: `%s`"
-					commandList.showError(channelID, fmt.Sprintf(output, folderPathOrID))
-					return
-				}
-			}
-
-			output := "successfully set root folder to `%s`"
-			commandList.discord.ChannelMessageSend(channelID, fmt.Sprintf(output, defaultSonarrPath))
-
-		default:
-			output := "unknown media type: %s\n\tshould be one of `movie|show`"
-			commandList.discord.ChannelMessageSend(channelID, fmt.Sprintf(output, mediaType))
+		if err != nil {
+			channelLogger(channelID).Info("could not resolve root folder", "backend", backend.Name(), "error", err)
+			commandList.showError(channelID, err.Error())
 			return
 		}
+
+		*backend.Settings().rootFolderPath = folder.path
+
+		commandList.send(channelID, fmt.Sprintf("successfully set root folder to %s", folder))
 	}
 }
 
 func addMedia(commandList d, services clients) func(channelID string, args ...string) {
 	return func(channelID string, args ...string) {
+		args, options, err := parseAddArgs(args)
+
+		if err != nil {
+			commandList.showError(channelID, err.Error())
+			return
+		}
+
 		argCount := len(args)
 
 		// we should have 2 args
 		if argCount < 2 {
-			commandList.showError(channelID, "`movie|show <tmdb-id>`")
+			commandList.showError(channelID, "`movie|show <tmdb-id|tvdb-id|imdb-id|link|title (year)> [--quality <name|id>] [--folder <path|id>] [--availability announced|inCinemas|released] [--type standard|daily|anime] [--language <name|id>] [--season-folders|--no-season-folders] [--no-search] [--unmonitored] [--tags a,b]`")
 			return
 		}
 
 		// first arg should be 'movie' or 'show'
-		mediaType := args[0]
-		mediaID := args[1]
+		mediaType := resolveMediaType(args[0])
+		mediaID := strings.TrimSpace(strings.Join(args[1:], " "))
 
 		if mediaID == "" {
 			commandList.showError(channelID, "a tmdb/tvdb id is required\n `movie|show <id>`")
 			return
 		}
 
-		switch mediaType {
-		case "movie":
-			// use radarr to add movie
-			tmdbID, err := strconv.Atoi(mediaID)
+		backend, ok := mediaBackendFor(commandList, services, channelID, mediaType)
 
-			if err != nil {
-				output := fmt.Sprintf("failed to convert tmdb id to int: %v", err)
-				fmt.Printf("channel id: %s - %s\n", channelID, output)
-				commandList.showError(channelID, output)
-				return
-			}
-
-			// make sure profile quality and folder path are set
-			if defaultRadarrPath == "" {
-				commandList.showError(channelID, "aborting... a root folder path must be set")
-				commandList.showHelp(channelID)
-				return
-			}
-
-			if defaultRadarrQualityID == 0 {
-				commandList.showError(channelID, "aborting... a profile quality must be set")
-				commandList.showHelp(channelID)
-				return
-			}
-
-			requestedMovie, err := services.radarr.GetMovie(tmdbID)
-
-			if err != nil {
-				fmt.Printf("failed to add movie: %v\n", err)
-				commandList.showError(channelID, fmt.Sprintf("failed fetching movie: %v", err))
-				return
-			}
-
-			// tweak fields to make a proper request
-			requestedMovie.AddOptions.SearchForMovie = true
-			requestedMovie.Monitored = true
-			requestedMovie.QualityProfileID = defaultRadarrQualityID
-			requestedMovie.RootFolderPath = defaultRadarrPath
+		if !ok {
+			return
+		}
 
-			if errors := services.radarr.AddMovie(requestedMovie); errors != nil {
-				output := ""
-				logOutput := ""
+		if err := backend.CheckAddOptions(options); err != nil {
+			commandList.showError(channelID, err.Error())
+			return
+		}
 
-				for _, err := range errors {
-					if err == radarr.ErrorMovieExists {
-						output += "`" + requestedMovie.Title + " (" + strconv.Itoa(requestedMovie.Year) + ")` is already added"
-					}
+		id, err := backend.Lookup(mediaID)
 
-					logOutput += err.Error() + "\n"
+		if err != nil {
+			channelLogger(channelID).Info("could not resolve media", "backend", backend.Name(), "error", err)
+			commandList.showError(channelID, err.Error())
+			return
+		}
 
-				}
+		settings := backend.Settings()
 
-				fmt.Printf("failed to add movie - channel id: %s - %s\n", channelID, logOutput)
-				commandList.discord.ChannelMessageSend(channelID, output)
-				return
-			}
+		request := addRequest{
+			channelID:        channelID,
+			rootFolderPath:   *settings.rootFolderPath,
+			qualityProfileID: *settings.qualityProfileID,
+			options:          options,
+		}
 
-			output := fmt.Sprintf("successfully added `%s (%d)`", requestedMovie.Title, requestedMovie.Year)
-			commandList.discord.ChannelMessageSend(channelID, output)
-		case "show":
-			// use sonarr to add movie
-			tvdbID, err := strconv.Atoi(mediaID)
+		if options.folder != "" {
+			folder, err := resolveRootFolder(backend, options.folder)
 
 			if err != nil {
-				output := fmt.Sprintf("failed to convert tvdb id to int: %v", err)
-				fmt.Printf("channel id: %s - %s\n", channelID, output)
-				commandList.showError(channelID, output)
-				return
-			}
-
-			// make sure profile quality and folder path are set
-			if defaultSonarrPath == "" {
-				commandList.showError(channelID, "aborting... a root folder path must be set")
-				commandList.showHelp(channelID)
-				return
-			}
-
-			if defaultSonarrQualityID == 0 {
-				commandList.showError(channelID, "aborting... a profile quality must be set")
-				commandList.showHelp(channelID)
+				commandList.showError(channelID, err.Error())
 				return
 			}
 
-			// commandList.showError(channelID, "Sorry, `show` is not implemented!")
+			request.rootFolderPath = folder.path
+		}
 
-			requestedShow, err := services.sonarr.GetSeriesFromTVDB(tvdbID)
+		if options.quality != "" {
+			profile, err := resolveProfile(backend, options.quality)
 
 			if err != nil {
-				fmt.Printf("failed to add show: %v\n", err)
-				commandList.showError(channelID, fmt.Sprintf("failed fetching show: %v", err))
+				commandList.showError(channelID, err.Error())
 				return
 			}
 
-			// tweak fields to make a proper request
-			requestedShow.AddOptions.SearchForMissingEpisodes = true
-			requestedShow.Monitored = true
-			requestedShow.QualityProfileID = defaultSonarrQualityID
-			requestedShow.Path = defaultSonarrPath + requestedShow.Title
+			request.qualityProfileID = profile.id
+		}
 
-			if errors := services.sonarr.AddSeries(*requestedShow); errors != nil {
-				output := ""
-				logOutput := ""
+		// make sure profile quality and folder path are set
+		if request.rootFolderPath == "" {
+			commandList.showError(channelID, "aborting... a root folder path must be set")
+			commandList.showHelp(channelID)
+			return
+		}
 
-				for _, err := range errors {
-					if err == sonarr.ErrorSeriesExists {
-						output += "`" + requestedShow.Title + " (" + strconv.Itoa(requestedShow.Year) + ")` is already added"
-					}
+		if request.qualityProfileID == 0 {
+			commandList.showError(channelID, "aborting... a profile quality must be set")
+			commandList.showHelp(channelID)
+			return
+		}
 
-					logOutput += err.Error() + "\n"
-				}
+		added, err := backend.Add(id, request)
 
-				fmt.Printf("failed to add show - channel id: %s - %s\n", channelID, logOutput)
-				commandList.discord.ChannelMessageSend(channelID, output)
-				return
-			}
+		if err == errAlreadyAdded {
+			channelLogger(channelID).Info("media already added", "backend", backend.Name(), "id", id)
+			commandList.showError(channelID, fmt.Sprintf("`%s (%d)` is already added", added.title, added.year))
+			return
+		}
 
-			output := fmt.Sprintf("successfully added `%s (%d)`", requestedShow.Title, requestedShow.Year)
-			commandList.discord.ChannelMessageSend(channelID, output)
+		if err != nil {
+			channelLogger(channelID).Error("failed to add media", "backend", backend.Name(), "error", err)
+			commandList.showError(channelID, fmt.Sprintf("failed to add %s: %v", mediaType, err))
+			return
+		}
 
-		default:
-			commandList.showError(channelID, "`movie|show <tmdb-id>`")
+		output := fmt.Sprintf("successfully added `%s (%d)`", added.title, added.year)
+
+		if added.path != "" {
+			output += fmt.Sprintf(" to `%s`", added.path)
 		}
+
+		commandList.send(channelID, output)
 	}
 }
 
-// I believe radarr only has this feature
 func discoverMedia(commandList d, services clients) func(channelID string, args ...string) {
 	return func(channelID string, args ...string) {
 		argCount := len(args)
@@ -652,43 +725,48 @@ func discoverMedia(commandList d, services clients) func(channelID string, args
 			return
 		}
 
-		mediaType := args[0]
-
-		switch mediaType {
-		case "movie":
-			movies, err := services.radarr.DiscoverMovies()
-
-			if err != nil {
-				output := fmt.Sprintf("fetch movies failed: %v", err)
-
-				fmt.Printf("%v - %s - %s\n", time.Now().String(), channelID, output)
-
-				commandList.showError(channelID, output)
-				return
-			}
+		backend, ok := mediaBackendFor(commandList, services, channelID, resolveMediaType(args[0]))
 
-			output := "Here are your recommended movies:\n"
+		if !ok {
+			return
+		}
 
-			for _, movie := range movies {
-				output += fmt.Sprintf("\t- %s (%d): %s\n", movie.Title, movie.Year, movie.Overview)
-			}
+		recommended, err := backend.Discover()
 
-			if _, err := commandList.discord.ChannelMessageSend(channelID, output); err != nil {
-				fmt.Printf("%v - %s - %v\n", time.Now().String(), channelID, err)
-			}
-		default:
-			output := fmt.Sprintf("unknown media type: %s\nit should be `movie` or `show`", mediaType)
+		if err != nil {
+			channelLogger(channelID).Error("discover failed", "backend", backend.Name(), "error", err)
+			commandList.showError(channelID, err.Error())
+			return
+		}
 
-			fmt.Printf("%v - %s - %s", time.Now().String(), channelID, output)
+		output := fmt.Sprintf("Here are your recommended %ss:\n", resolveMediaType(args[0]))
 
-			commandList.showError(channelID, output)
+		for _, item := range recommended {
+			output += fmt.Sprintf("\t- %s (%d): %s\n", item.title, item.year, item.overview)
 		}
+
+		commandList.send(channelID, output)
 	}
 }
 
+// libraryFilters narrow down what `library` lists
+var libraryFilters = map[string]func(item media) bool{
+	"monitored":  func(item media) bool { return item.monitored },
+	"downloaded": func(item media) bool { return item.downloaded },
+	"missing":    func(item media) bool { return !item.downloaded },
+	"released":   func(item media) bool { return item.releaseStatus == "released" },
+	"announced":  func(item media) bool { return item.releaseStatus == "announced" },
+	"cinemas":    func(item media) bool { return item.releaseStatus == "inCinemas" },
+	"continuing": func(item media) bool { return item.releaseStatus == "continuing" },
+	"ended":      func(item media) bool { return item.releaseStatus == "ended" },
+}
+
+// libraryPageSize keeps a page of the library under discord's 2000 character limit
+const libraryPageSize = 40
+
 func showLibrary(commandList d, services clients) func(channelID string, args ...string) {
 	return func(channelID string, args ...string) {
-		// command: library <movie|show> [missing, downloaded, or missing] <page-number>
+		// command: library <movie|show> [filter] <page-number>
 		// page number is optional -- w/o page number we'll show the first page of results
 		//
 		// examples:
@@ -696,7 +774,7 @@ func showLibrary(commandList d, services clients) func(channelID string, args ..
 		// library movie 3
 		// library movie missing
 		// library movie missing 3
-		// library movie downloaded 6
+		// library show continuing 2
 
 		argCount := len(args)
 
@@ -705,155 +783,101 @@ func showLibrary(commandList d, services clients) func(channelID string, args ..
 			return
 		}
 
-		mediaType := args[0]
+		mediaType := resolveMediaType(args[0])
+
+		backend, ok := mediaBackendFor(commandList, services, channelID, mediaType)
+
+		if !ok {
+			return
+		}
 
 		args = args[1:argCount]
 		argCount--
 
 		// args should be: "", "1" (page), "monitored" (a filter type), "monitored 2" (a filter type + page number)
 
-		page := "1"
-		pageSize := "40"
-
-		switch mediaType {
-		case "movie":
-			options := radarr.GetMovieOptions{
-				Page:     page,
-				PageSize: pageSize,
-				SortKey:  "sortTitle",
-				SortDir:  "asc",
-			}
-
-			if argCount > 0 {
-				// check for a page number
-				// if successful there's no filter
-				if _, err := strconv.Atoi(args[0]); err != nil {
-					// we could not convert so we most likely have a filter
-					filter := args[0]
-					filterValue := "true"
-
-					switch filter {
-					case "monitored":
-						filter = "monitored"
-					case "downloaded":
-						filter = "downloaded"
-					case "missing":
-						filter = "downloaded"
-						filterValue = "false"
-					case "released":
-						filter = "status"
-						filterValue = "released"
-					case "announced":
-						filter = "status"
-						filterValue = "announced"
-					case "cinemas":
-						filter = "status"
-						filterValue = "inCinemas"
-					default:
-						commandList.showError(channelID, fmt.Sprintf("unknown filter `%s` for command `library movie`", filter))
-						return
-					}
+		page := 1
+		var filter func(item media) bool
 
-					options.FilterKey = filter
-					options.FilterValue = filterValue
-					options.FilterType = "equal"
+		if argCount > 0 {
+			// check for a page number
+			// if successful there's no filter
+			if number, err := strconv.Atoi(args[0]); err == nil {
+				page = number
+			} else {
+				// we could not convert so we most likely have a filter
+				if filter, ok = libraryFilters[args[0]]; !ok {
+					commandList.showError(channelID, fmt.Sprintf("unknown filter `%s` for command `library %s`", args[0], mediaType))
+					return
+				}
 
-					// check for page number
-					if argCount > 1 {
-						if _, err := strconv.Atoi(args[1]); err == nil {
-							page = args[1]
-							options.Page = page
-						}
+				// check for page number
+				if argCount > 1 {
+					if number, err := strconv.Atoi(args[1]); err == nil {
+						page = number
 					}
-
-				} else {
-					// we converted the argument to a number
-					// so we have a page number
-					page = args[0]
-					options.Page = page
 				}
 			}
+		}
 
-			movies, err := services.radarr.GetMovies(options)
+		items, err := backend.Library()
 
-			if err != nil {
-				output := fmt.Sprintf("fetch movies from radarr failed: %v", err)
+		if err != nil {
+			commandList.showError(channelID, err.Error())
+			channelLogger(channelID).Error("fetch library failed", "backend", backend.Name(), "error", err)
+			return
+		}
 
-				commandList.showError(channelID, output)
-				logPrint(channelID, output)
-				return
-			}
+		var matches []media
 
-			movieCount := len(movies)
-			// the output preface has 35 chars
-			output := fmt.Sprintf("showing %d movies on page %s:\n\n",
-				movieCount, page)
-			titleLen := 0
-			yearLen := 0
-
-			// no movies but there is a page argument
-			if movieCount < 1 && page != "" {
-				output += "uh oh! try going back a page!"
-			} else if movieCount < 1 && page == "" {
-				output += "add some movies to your library! :smile:"
+		for _, item := range items {
+			if filter == nil || filter(item) {
+				matches = append(matches, item)
 			}
+		}
 
-			for _, movie := range movies {
-				yearStr := strconv.Itoa(movie.Year)
-
-				// '<title> (2000) - downloaded\n'
-				//  <-x-->|<------26 chars ---->|
-				//
-				// x == title; average char length is 14
-				// if not downloaded subtract 13 chars
-				// if downloaded total would be 82
-				// not downloaded total is 69 for each movie
-				//
-				// we can average ~50 movies before we need to go to the next page
-				output += movie.Title + " (" + yearStr + ") "
-
-				if movie.Downloaded {
-					output += " - `downloaded`"
-				}
+		start := (page - 1) * libraryPageSize
 
-				output += "\n"
+		if page < 1 || start >= len(matches) {
+			matches = nil
+		} else {
+			matches = matches[start:]
+		}
 
-				titleLen += len(movie.Title)
-				yearLen += len(yearStr)
-			}
+		if len(matches) > libraryPageSize {
+			matches = matches[:libraryPageSize]
+		}
 
-			if isVerbose {
-				// get the average movie + year length to determine how many movies we can show in discord
-				// without going over the 2000 char limit
-				fmt.Printf("movie count: %d\n", movieCount)
-				fmt.Printf("\ttotal title length: %d\n\ttotal year length: %d\n", titleLen, yearLen)
+		count := len(matches)
+		output := fmt.Sprintf("showing %d %ss on page %d:\n\n", count, mediaType, page)
 
-				averageTitleLen := 0
-				averageYearLen := 0
+		if count < 1 && page > 1 {
+			output += "uh oh! try going back a page!"
+		} else if count < 1 {
+			output += fmt.Sprintf("add some %ss to your library! :smile:", mediaType)
+		}
 
-				if movieCount != 0 {
-					averageTitleLen = titleLen / movieCount
-					averageYearLen = yearLen / movieCount
-				}
+		for _, item := range matches {
+			// '<title> (2000) - downloaded\n'
+			// a page of 40 stays well under discord's 2000 character limit
+			output += item.title + " (" + strconv.Itoa(item.year) + ") "
 
-				fmt.Printf("\taverage title length: %d\n\taverage year length: %d\n", averageTitleLen, averageYearLen)
-				fmt.Printf("\ttotal message length: %d\n", len(output))
+			if item.downloaded {
+				output += " - `downloaded`"
 			}
 
-			if _, err := commandList.discord.ChannelMessageSend(channelID, output); err != nil {
-				fmt.Printf("message sent to discord failed: %v\n", err)
-				commandList.discord.ChannelMessageSend(channelID, fmt.Sprintf("could not reply back: %v", err))
-			}
-		case "show":
-			output := "`library show` not implemented"
-			if _, err := commandList.discord.ChannelMessageSend(channelID, output); err != nil {
-				fmt.Printf("message sent to discord failed: %v\n", err)
-				commandList.discord.ChannelMessageSend(channelID, fmt.Sprintf("could not reply back: %v", err))
-			}
-		default:
-			output := "unknown command"
+			output += "\n"
+		}
+
+		if isDebug() {
+			channelLogger(channelID).Debug("library page length",
+				"media_count", count,
+				"message_length", len(output),
+			)
+		}
 
-			logPrint(channelID, output)
+		if err := commandList.send(channelID, output); err != nil {
+			commandList.showError(channelID, fmt.Sprintf("could not reply back: %v", err))
 		}
 	}
 }
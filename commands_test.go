@@ -0,0 +1,689 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestSearch(t *testing.T) {
+	runCommandCases(t, "search", []commandCase{
+		{
+			name: "movie",
+			args: []string{"movie", "sicario"},
+			want: []string{"Here are your search results for `sicario`:\n" +
+				"- Sicario (2015) `273481` - downloaded\n" +
+				"- Sicario: Day of the Soldado (2018) `400535`\n" +
+				"- Sicario (1994) `95700`\n"},
+			request: "radarr GET /api/v3/movie/lookup",
+			query:   map[string]string{"term": "sicario"},
+		},
+		{
+			name: "show",
+			args: []string{"tv", "breaking", "bad"},
+			want: []string{"Here are your search results for `breaking bad`:\n" +
+				"- Breaking Bad (2008) `81189` - downloaded\n" +
+				"- Breaking Bad: Original Minisodes (2009) `252135`\n"},
+			request: "sonarr GET /api/v3/series/lookup",
+			query:   map[string]string{"term": "breaking bad"},
+		},
+		{
+			name: "no results",
+			args: []string{"movie", "zzz"},
+			setup: func(h *harness) {
+				h.radarr.respond("GET /api/v3/movie/lookup?term=zzz", 200, "[]")
+			},
+			want:    []string{"No results found"},
+			request: "radarr GET /api/v3/movie/lookup",
+			query:   map[string]string{"term": "zzz"},
+		},
+		{
+			name: "backend error",
+			args: []string{"movie", "sicario"},
+			setup: func(h *harness) {
+				h.radarr.respond("GET /api/v3/movie/lookup?term=sicario", 500, "")
+			},
+			want: []string{"search failed: 500 Internal Server Error"},
+		},
+		{
+			name: "missing title",
+			args: []string{"movie"},
+			want: []string{"search requires `<movie|show> <title>`\n"},
+		},
+	})
+}
+
+func TestCommandsInTheSameChannelRunTogether(t *testing.T) {
+	h := newHarness(t)
+
+	release := h.radarr.stall("GET /api/v3/movie/lookup?term=sicario", 200, "[]")
+
+	stalled := make(chan []string)
+
+	go func() {
+		stalled <- h.run("search", "movie", "sicario")
+	}()
+
+	quick := make(chan []string)
+
+	go func() {
+		quick <- h.run("quality", "movie")
+	}()
+
+	select {
+	case got := <-quick:
+		want := []string{"Here are the available quality profiles for radarr:\n" +
+			"\t`id: 1` Any\n" +
+			"\t`id: 4` HD-1080p\n" +
+			"\t`id: 5` Ultra-HD\n"}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("quality replied %q, want %q", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("quality waited on a search stuck talking to radarr")
+	}
+
+	release()
+
+	if got, want := <-stalled, []string{"No results found"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("search replied %q, want %q", got, want)
+	}
+}
+
+// snowflake makes a discord id created at t
+func snowflake(t time.Time, sequence int64) string {
+	const discordEpoch = 1420070400000
+
+	ms := t.UnixNano()/int64(time.Millisecond) - discordEpoch
+
+	return strconv.FormatInt(ms<<22|sequence, 10)
+}
+
+func TestClear(t *testing.T) {
+	now := time.Now()
+
+	botReply := snowflake(now.Add(-time.Minute), 1)
+	command := snowflake(now.Add(-2*time.Minute), 2)
+	chatter := snowflake(now.Add(-3*time.Minute), 3)
+	oldCommand := snowflake(now.Add(-30*24*time.Hour), 4)
+
+	history := []*discordgo.Message{
+		{ID: botReply, Content: "removed 3 messages", Author: &discordgo.User{ID: fakeBotID}},
+		{ID: command, Content: "shart search movie sicario", Author: &discordgo.User{ID: "1"}},
+		{ID: chatter, Content: "what are we watching tonight", Author: &discordgo.User{ID: "2"}},
+		{ID: oldCommand, Content: "shart add movie 273481", Author: &discordgo.User{ID: "1"}},
+	}
+
+	withHistory := func(h *harness) {
+		h.chat.history[testChannel] = history
+	}
+
+	deleted := func(ids ...string) func(t *testing.T, h *harness) {
+		return func(t *testing.T, h *harness) {
+			if !reflect.DeepEqual(h.chat.deleted, ids) {
+				t.Errorf("deleted %v, want %v", h.chat.deleted, ids)
+			}
+		}
+	}
+
+	runCommandCases(t, "clear", []commandCase{
+		{
+			name:  "everything",
+			setup: withHistory,
+			want:  []string{"removed 4 messages"},
+			check: deleted(botReply, command, chatter, oldCommand),
+		},
+		{
+			name:  "count",
+			args:  []string{"2"},
+			setup: withHistory,
+			want:  []string{"removed 2 messages"},
+			check: deleted(botReply, command),
+		},
+		{
+			name:  "bot only",
+			args:  []string{"--bot-only"},
+			setup: withHistory,
+			want:  []string{"removed 1 messages"},
+			check: deleted(botReply),
+		},
+		{
+			name:  "commands only",
+			args:  []string{"--commands-only"},
+			setup: withHistory,
+			want:  []string{"removed 2 messages"},
+			check: deleted(command, oldCommand),
+		},
+		{
+			name:  "user",
+			args:  []string{"--user", "<@!2>"},
+			setup: withHistory,
+			want:  []string{"removed 1 messages"},
+			check: deleted(chatter),
+		},
+		{
+			name:  "before",
+			args:  []string{"--before", command},
+			setup: withHistory,
+			want:  []string{"removed 2 messages"},
+			check: deleted(chatter, oldCommand),
+		},
+		{
+			name:  "zero",
+			args:  []string{"0"},
+			setup: withHistory,
+			want:  []string{"the number of messages to clear must be at least 1, got `0`\n`clear [n] [--bot-only] [--commands-only] [--user @someone] [--before message-id]`"},
+			check: deleted(),
+		},
+		{
+			name: "negative count",
+			args: []string{"-3"},
+			want: []string{"the number of messages to clear must be at least 1, got `-3`\n`clear [n] [--bot-only] [--commands-only] [--user @someone] [--before message-id]`"},
+		},
+		{
+			name: "unknown option",
+			args: []string{"--everyone"},
+			want: []string{"unknown option `--everyone`\n`clear [n] [--bot-only] [--commands-only] [--user @someone] [--before message-id]`"},
+		},
+	})
+}
+
+// withAddDefaults sets the root folders and quality profiles `add` falls back on
+func withAddDefaults(h *harness) {
+	defaultRadarrPath = "/movies"
+	defaultRadarrQualityID = 4
+	defaultSonarrPath = "/tv"
+	defaultSonarrQualityID = 6
+}
+
+// nothingSent fails when a request was made to a path
+func nothingSent(backend, method, path string) func(t *testing.T, h *harness) {
+	return func(t *testing.T, h *harness) {
+		fake := h.radarr
+
+		if backend == "sonarr" {
+			fake = h.sonarr
+		}
+
+		if sent := fake.sent(method, path); len(sent) > 0 {
+			t.Errorf("%s %s %s should not have been sent, got %v", backend, method, path, sent)
+		}
+	}
+}
+
+// withAddDefaultsQuiet is withAddDefaults with the requests made starting
+// radarr and sonarr forgotten, for checking with noRequests
+func withAddDefaultsQuiet(h *harness) {
+	withAddDefaults(h)
+
+	h.radarr.forget()
+	h.sonarr.forget()
+}
+
+// noRequests fails when the command made any request to radarr or sonarr
+func noRequests(t *testing.T, h *harness) {
+	for _, fake := range []*fakeArr{h.radarr, h.sonarr} {
+		if sent := fake.all(); len(sent) > 0 {
+			t.Errorf("%s should not have been sent anything, got %v", fake.name, sent)
+		}
+	}
+}
+
+func TestAddMovie(t *testing.T) {
+	runCommandCases(t, "add", []commandCase{
+		{
+			name:    "by id",
+			args:    []string{"movie", "400535"},
+			setup:   withAddDefaults,
+			want:    []string{"successfully added `Sicario: Day of the Soldado (2018)`"},
+			request: "radarr POST /api/v3/movie",
+			body: map[string]interface{}{
+				"tmdbId":                    float64(400535),
+				"title":                     "Sicario: Day of the Soldado",
+				"qualityProfileId":          float64(4),
+				"rootFolderPath":            "/movies",
+				"monitored":                 true,
+				"minimumAvailability":       "announced",
+				"addOptions.searchForMovie": true,
+			},
+		},
+		{
+			name: "link with options",
+			args: []string{"movie", "https://www.themoviedb.org/movie/400535-sicario-day-of-the-soldado",
+				"--quality", "ultra-hd", "--folder", "2", "--availability", "released",
+				"--unmonitored", "--no-search", "--tags", "4k,kids"},
+			want:    []string{"successfully added `Sicario: Day of the Soldado (2018)`"},
+			request: "radarr POST /api/v3/movie",
+			body: map[string]interface{}{
+				"tmdbId":                    float64(400535),
+				"qualityProfileId":          float64(5),
+				"rootFolderPath":            "/kids",
+				"monitored":                 false,
+				"minimumAvailability":       "released",
+				"addOptions.searchForMovie": false,
+				"tags":                      []interface{}{float64(1), float64(2)},
+			},
+			check: func(t *testing.T, h *harness) {
+				h.assertRequest("radarr POST /api/v3/tag", nil, map[string]interface{}{"label": "kids"})
+			},
+			// the v2 client sends tag ids as strings
+			legacy: &commandCase{body: map[string]interface{}{"tags": []interface{}{"1", "2"}}},
+		},
+		{
+			name:    "title",
+			args:    []string{"movie", "sicario", "2015"},
+			setup:   withAddDefaults,
+			want:    []string{"successfully added `Sicario (2015)`"},
+			request: "radarr POST /api/v3/movie",
+			body:    map[string]interface{}{"tmdbId": float64(273481), "tags": []interface{}{float64(1)}},
+			legacy:  &commandCase{body: map[string]interface{}{"tags": []interface{}{}}},
+		},
+		{
+			name:  "ambiguous title",
+			args:  []string{"movie", "dune"},
+			setup: withAddDefaults,
+			want: []string{"`dune` could be more than one movie, add it by id instead:\n" +
+				"- Dune (2021) `438631`\n" +
+				"- Dune: Part Two (2024) `693134`\n" +
+				"- Dune (1984) `841`\n"},
+			check: nothingSent("radarr", "POST", "/api/v3/movie"),
+		},
+		{
+			name: "already added",
+			args: []string{"movie", "273481"},
+			setup: func(h *harness) {
+				withAddDefaults(h)
+				h.radarr.respond("POST /api/v3/movie", 400,
+					`[{"propertyName":"TmdbId","errorMessage":"This movie has already been added","attemptedValue":273481}]`)
+			},
+			want:  []string{"`Sicario (2015)` is already added"},
+			check: nothingSent("radarr", "POST", "/api/v3/tag"),
+		},
+		{
+			name:  "show only flag",
+			args:  []string{"movie", "400535", "--folder", "/kids", "--quality", "4", "--type", "anime"},
+			setup: withAddDefaultsQuiet,
+			want:  []string{"`--type` only applies to shows"},
+			check: noRequests,
+		},
+		{
+			name:  "unknown quality",
+			args:  []string{"movie", "400535", "--quality", "9"},
+			setup: withAddDefaults,
+			want:  []string{"could not find a quality profile with id `9`, try one of `Any`, `HD-1080p`, `Ultra-HD`"},
+			check: nothingSent("radarr", "POST", "/api/v3/movie"),
+		},
+		{
+			name: "no root folder",
+			args: []string{"movie", "400535"},
+			check: func(t *testing.T, h *harness) {
+				replies := h.chat.messages(testChannel)
+
+				if len(replies) != 2 || replies[0] != "aborting... a root folder path must be set" {
+					t.Errorf("replied %q", replies)
+				}
+
+				nothingSent("radarr", "POST", "/api/v3/movie")(t, h)
+			},
+		},
+	})
+}
+
+func TestAddShow(t *testing.T) {
+	runCommandCases(t, "add", []commandCase{
+		{
+			name:    "title",
+			args:    []string{"show", "the", "bear"},
+			setup:   withAddDefaults,
+			want:    []string{"successfully added `The Bear (2022)` to `/tv/The Bear`"},
+			request: "sonarr POST /api/v3/series",
+			body: map[string]interface{}{
+				"tvdbId":                              float64(403245),
+				"title":                               "The Bear",
+				"path":                                "/tv/The Bear",
+				"qualityProfileId":                    float64(6),
+				"seriesType":                          "standard",
+				"seasonFolder":                        true,
+				"monitored":                           true,
+				"languageProfileId":                   float64(1),
+				"addOptions.searchForMissingEpisodes": true,
+			},
+			// sonarr v2 has no language profiles
+			legacy: &commandCase{body: map[string]interface{}{"languageProfileId": nil}},
+		},
+		{
+			name: "no language profiles",
+			args: []string{"show", "403245"},
+			setup: func(h *harness) {
+				withAddDefaults(h)
+				h.sonarr.respond("GET /api/v3/languageprofile", 200, "[]")
+			},
+			want:  []string{"failed to add show: sonarr has no language profiles to add the show with"},
+			check: nothingSent("sonarr", "POST", "/api/v3/series"),
+			legacy: &commandCase{
+				want:    []string{"successfully added `The Bear (2022)` to `/tv/The Bear`"},
+				request: "sonarr POST /api/v3/series",
+				body:    map[string]interface{}{"languageProfileId": nil},
+				check:   nothingSent("sonarr", "GET", "/api/v3/languageprofile"),
+			},
+		},
+		{
+			name: "title without a folder name",
+			args: []string{"show", "403245"},
+			setup: func(h *harness) {
+				withAddDefaults(h)
+				h.sonarr.respond("GET /api/v3/series/lookup?term=tvdb%3A403245", 200, `[{"title": "<|>", "year": 2022, "tvdbId": 403245}]`)
+			},
+			want:  []string{"failed to add show: `<|>` leaves no folder name with the series folder format `{Title}`"},
+			check: nothingSent("sonarr", "POST", "/api/v3/series"),
+		},
+		{
+			name: "options",
+			args: []string{"series", "403245", "--folder", "/anime", "--type", "anime", "--language", "japanese",
+				"--no-season-folders", "--unmonitored", "--no-search", "--tags", "kids"},
+			setup:   withAddDefaults,
+			want:    []string{"successfully added `The Bear (2022)` to `/anime/The Bear`"},
+			request: "sonarr POST /api/v3/series",
+			body: map[string]interface{}{
+				"path":                                "/anime/The Bear",
+				"qualityProfileId":                    float64(6),
+				"seriesType":                          "anime",
+				"seasonFolder":                        false,
+				"monitored":                           false,
+				"languageProfileId":                   float64(2),
+				"addOptions.searchForMissingEpisodes": false,
+				"tags":                                []interface{}{float64(1)},
+			},
+			legacy: &commandCase{
+				want:    []string{"failed to add show: fetch sonarr language profiles failed (they need sonarr v3): 404 Not Found"},
+				request: "sonarr GET /api/v3/languageprofile",
+				check:   nothingSent("sonarr", "POST", "/api/v3/series"),
+			},
+		},
+		{
+			name: "channel defaults",
+			args: []string{"show", "403245"},
+			setup: func(h *harness) {
+				withAddDefaults(h)
+				h.run("defaults", "show", "--type", "daily", "--language", "1")
+			},
+			want:    []string{"successfully added `The Bear (2022)` to `/tv/The Bear`"},
+			request: "sonarr POST /api/v3/series",
+			body: map[string]interface{}{
+				"seriesType":        "daily",
+				"languageProfileId": float64(1),
+			},
+			legacy: &commandCase{
+				setup: func(h *harness) {
+					withAddDefaults(h)
+					h.run("defaults", "show", "--type", "daily")
+				},
+				body: map[string]interface{}{"languageProfileId": nil},
+			},
+		},
+		{
+			name: "already added",
+			args: []string{"show", "81189"},
+			setup: func(h *harness) {
+				withAddDefaults(h)
+				h.sonarr.respond("POST /api/v3/series", 400,
+					`[{"propertyName":"TvdbId","errorMessage":"This series has already been added","attemptedValue":81189}]`)
+			},
+			want: []string{"`Breaking Bad (2008)` is already added"},
+		},
+		{
+			name:  "movie only flag",
+			args:  []string{"show", "breaking", "bad", "--folder", "/tv", "--quality", "6", "--availability", "released"},
+			setup: withAddDefaultsQuiet,
+			want:  []string{"`--availability` only applies to movies"},
+			check: noRequests,
+		},
+		{
+			name:  "unknown folder",
+			args:  []string{"show", "403245", "--folder", "/movies"},
+			setup: withAddDefaults,
+			want: []string{"`/movies` is not a root folder, pick one of:\n" +
+				"`1` `/tv` (1.0 TB free)\n" +
+				"`3` `/anime` (200.0 GB free)"},
+			check: nothingSent("sonarr", "POST", "/api/v3/series"),
+		},
+	})
+}
+
+func TestQuality(t *testing.T) {
+	runCommandCases(t, "quality", []commandCase{
+		{
+			name: "movie",
+			args: []string{"movie"},
+			want: []string{"Here are the available quality profiles for radarr:\n" +
+				"\t`id: 1` Any\n" +
+				"\t`id: 4` HD-1080p\n" +
+				"\t`id: 5` Ultra-HD\n"},
+			request: "radarr GET /api/v3/qualityprofile",
+		},
+		{
+			name: "show",
+			args: []string{"show"},
+			want: []string{"Here are the available quality profiles for sonarr:\n" +
+				"\t`id: 1` Any\n" +
+				"\t`id: 6` HD-1080p\n"},
+			request: "sonarr GET /api/v3/qualityprofile",
+		},
+		{
+			name: "backend error",
+			args: []string{"movie"},
+			setup: func(h *harness) {
+				h.radarr.respond("GET /api/v3/qualityprofile", 500, "")
+			},
+			want: []string{"fetch radarr quality profiles failed: 500 Internal Server Error"},
+		},
+		{
+			name: "missing media type",
+			want: []string{"an arg `movie|show` is required"},
+		},
+	})
+}
+
+func TestFolders(t *testing.T) {
+	runCommandCases(t, "folders", []commandCase{
+		{
+			name: "movie",
+			args: []string{"movie"},
+			want: []string{"Here are the available root folders for radarr:\n" +
+				"\t`id: 1` - /movies 500.0 GB free of 4.0 TB\n" +
+				"\t`id: 2` - /kids 10.0 GB free of 100.0 GB\n"},
+			request: "radarr GET /api/v3/diskspace",
+		},
+		{
+			name: "show",
+			args: []string{"show"},
+			want: []string{"Here are the available root folders for sonarr:\n" +
+				"\t`id: 1` - /tv 1.0 TB free of 4.0 TB\n" +
+				"\t`id: 3` - /anime 200.0 GB free\n"},
+			request: "sonarr GET /api/v3/rootfolder",
+		},
+		{
+			name: "without disk space",
+			args: []string{"movie"},
+			setup: func(h *harness) {
+				h.radarr.respond("GET /api/v3/diskspace", 500, "")
+			},
+			want: []string{"Here are the available root folders for radarr:\n" +
+				"\t`id: 1` - /movies 500.0 GB free\n" +
+				"\t`id: 2` - /kids 10.0 GB free\n"},
+		},
+	})
+}
+
+func TestSetQuality(t *testing.T) {
+	qualityIs := func(movie, show int) func(t *testing.T, h *harness) {
+		return func(t *testing.T, h *harness) {
+			if defaultRadarrQualityID != movie || defaultSonarrQualityID != show {
+				t.Errorf("quality profiles are %d and %d, want %d and %d",
+					defaultRadarrQualityID, defaultSonarrQualityID, movie, show)
+			}
+		}
+	}
+
+	runCommandCases(t, "set-quality", []commandCase{
+		{
+			name:    "movie by name",
+			args:    []string{"movie", "hd", "1080p"},
+			want:    []string{"successfully set movie quality to `HD-1080p` (`4`)"},
+			request: "radarr GET /api/v3/qualityprofile",
+			check:   qualityIs(4, 0),
+		},
+		{
+			name:  "show by id",
+			args:  []string{"tv", "6"},
+			want:  []string{"successfully set show quality to `HD-1080p` (`6`)"},
+			check: qualityIs(0, 6),
+		},
+		{
+			name:  "unknown id",
+			args:  []string{"movie", "9"},
+			want:  []string{"could not find a quality profile with id `9`, try one of `Any`, `HD-1080p`, `Ultra-HD`"},
+			check: qualityIs(0, 0),
+		},
+		{
+			name:  "no close guesses",
+			args:  []string{"movie", "hd-720p"},
+			want:  []string{"could not find a quality profile named `hd-720p`, try one of `Any`, `HD-1080p`, `Ultra-HD`"},
+			check: qualityIs(0, 0),
+		},
+		{
+			name: "missing profile",
+			args: []string{"movie"},
+			want: []string{"need more args: `movie|show` <quality-profile-name|id>"},
+		},
+	})
+}
+
+func TestSetFolder(t *testing.T) {
+	folderIs := func(movie, show string) func(t *testing.T, h *harness) {
+		return func(t *testing.T, h *harness) {
+			if defaultRadarrPath != movie || defaultSonarrPath != show {
+				t.Errorf("root folders are %q and %q, want %q and %q",
+					defaultRadarrPath, defaultSonarrPath, movie, show)
+			}
+		}
+	}
+
+	runCommandCases(t, "set-folder", []commandCase{
+		{
+			name:    "movie by id",
+			args:    []string{"movie", "2"},
+			want:    []string{"successfully set root folder to `/kids` (10.0 GB free)"},
+			request: "radarr GET /api/v3/rootfolder",
+			check:   folderIs("/kids", ""),
+		},
+		{
+			name:    "show by path",
+			args:    []string{"show", "/tv/"},
+			want:    []string{"successfully set root folder to `/tv` (1.0 TB free)"},
+			request: "sonarr GET /api/v3/rootfolder",
+			check:   folderIs("", "/tv"),
+		},
+		{
+			name: "not a root folder",
+			args: []string{"movie", "/nope"},
+			want: []string{"`/nope` is not a root folder, pick one of:\n" +
+				"`1` `/movies` (500.0 GB free)\n" +
+				"`2` `/kids` (10.0 GB free)"},
+			check: folderIs("", ""),
+		},
+	})
+}
+
+func TestDiscover(t *testing.T) {
+	runCommandCases(t, "discover", []commandCase{
+		{
+			name: "movie",
+			args: []string{"movie"},
+			want: []string{"Here are your recommended movies:\n" +
+				"\t- Prisoners (2013): Keller Dover faces a parent's worst nightmare when his daughter goes missing.\n" +
+				"\t- Arrival (2016): A linguist works with the military to communicate with alien lifeforms.\n"},
+			request: "radarr GET /api/v3/importlist/movie",
+			query:   map[string]string{"includeRecommendations": "true"},
+			// v2 only has recommendations
+			legacy: &commandCase{query: map[string]string{}},
+		},
+		{
+			name: "show",
+			args: []string{"show"},
+			want: []string{"sonarr doesn't recommend shows, try `discover movie`"},
+		},
+		{
+			name: "backend error",
+			args: []string{"movie"},
+			setup: func(h *harness) {
+				h.radarr.respond("GET /api/v3/importlist/movie", 503, "")
+			},
+			want: []string{"fetch movies failed: 503 Service Unavailable"},
+		},
+	})
+}
+
+func TestLibrary(t *testing.T) {
+	runCommandCases(t, "library", []commandCase{
+		{
+			name: "movies",
+			args: []string{"movie"},
+			want: []string{"showing 4 movies on page 1:\n\n" +
+				"Blade Runner 2049 (2017) \n" +
+				"Dune: Part Three (2026) \n" +
+				"Dune: Part Two (2024) \n" +
+				"Sicario (2015)  - `downloaded`\n"},
+			request: "radarr GET /api/v3/movie",
+		},
+		{
+			name: "missing movies",
+			args: []string{"movie", "missing"},
+			want: []string{"showing 3 movies on page 1:\n\n" +
+				"Blade Runner 2049 (2017) \n" +
+				"Dune: Part Three (2026) \n" +
+				"Dune: Part Two (2024) \n"},
+		},
+		{
+			name: "announced movies",
+			args: []string{"movie", "announced"},
+			want: []string{"showing 1 movies on page 1:\n\nDune: Part Three (2026) \n"},
+		},
+		{
+			name: "past the last page",
+			args: []string{"movie", "monitored", "2"},
+			want: []string{"showing 0 movies on page 2:\n\nuh oh! try going back a page!"},
+		},
+		{
+			name: "empty library",
+			args: []string{"movie"},
+			setup: func(h *harness) {
+				h.radarr.respond("GET /api/v3/movie", 200, "[]")
+			},
+			want: []string{"showing 0 movies on page 1:\n\nadd some movies to your library! :smile:"},
+		},
+		{
+			name: "shows",
+			args: []string{"show"},
+			want: []string{"showing 3 shows on page 1:\n\n" +
+				"Better Call Saul (2015) \n" +
+				"Breaking Bad (2008)  - `downloaded`\n" +
+				"Severance (2022) \n"},
+			request: "sonarr GET /api/v3/series",
+		},
+		{
+			name: "continuing shows",
+			args: []string{"show", "continuing"},
+			want: []string{"showing 1 shows on page 1:\n\nSeverance (2022) \n"},
+		},
+		{
+			name: "unknown filter",
+			args: []string{"movie", "watched"},
+			want: []string{"unknown filter `watched` for command `library movie`"},
+		},
+	})
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// disk.go keeps an eye on how much room radarr and sonarr have left
+
+var (
+	// lowDiskThreshold is when a root folder counts as nearly full, e.g. `50GB` or `10%`
+	lowDiskThreshold string
+	// diskCheckInterval is how often root folders are checked
+	diskCheckInterval time.Duration
+)
+
+// diskSpace is a disk radarr or sonarr can see
+type diskSpace struct {
+	Path       string `json:"path"`
+	Label      string `json:"label"`
+	FreeSpace  int64  `json:"freeSpace"`
+	TotalSpace int64  `json:"totalSpace"`
+}
+
+func (disk diskSpace) String() string {
+	output := fmt.Sprintf("`%s` %s free of %s", disk.Path, formatBytes(disk.FreeSpace), formatBytes(disk.TotalSpace))
+
+	if disk.TotalSpace > 0 {
+		output += fmt.Sprintf(" (%.0f%%)", 100*float64(disk.FreeSpace)/float64(disk.TotalSpace))
+	}
+
+	if disk.Label != "" {
+		output += " " + disk.Label
+	}
+
+	return output
+}
+
+// fetchDiskSpace returns every disk a backend can see
+func fetchDiskSpace(api arrAPI) ([]diskSpace, error) {
+	var disks []diskSpace
+
+	err := api.get("/diskspace", nil, &disks)
+
+	return disks, err
+}
+
+// diskFor returns the disk a path lives on, the one with the longest matching mount point
+func diskFor(path string, disks []diskSpace) (diskSpace, bool) {
+	var match diskSpace
+	found := false
+
+	path = trimSeparators(path)
+
+	for _, disk := range disks {
+		mount := trimSeparators(disk.Path)
+
+		if !strings.HasPrefix(path, mount) {
+			continue
+		}
+
+		// `/mnt/media` isn't on `/mnt/med`
+		if len(path) > len(mount) && !strings.HasSuffix(mount, "/") && !strings.HasSuffix(mount, `\`) &&
+			path[len(mount)] != '/' && path[len(mount)] != '\\' {
+			continue
+		}
+
+		if !found || len(mount) > len(trimSeparators(match.Path)) {
+			match = disk
+			found = true
+		}
+	}
+
+	return match, found
+}
+
+// diskThreshold is either an amount of bytes or a percentage of the disk
+type diskThreshold struct {
+	bytes   int64
+	percent float64
+}
+
+// byteUnits are the suffixes parseDiskThreshold understands
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// parseDiskThreshold parses `50GB`, `1.5TB` or `10%`
+func parseDiskThreshold(str string) (diskThreshold, error) {
+	str = strings.ToUpper(strings.TrimSpace(str))
+
+	if strings.HasSuffix(str, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(str, "%"), 64)
+
+		if err != nil || percent <= 0 || percent >= 100 {
+			return diskThreshold{}, fmt.Errorf("invalid low disk threshold %q: percentages should be between 0 and 100", str)
+		}
+
+		return diskThreshold{percent: percent}, nil
+	}
+
+	for _, unit := range byteUnits {
+		if !strings.HasSuffix(str, unit.suffix) {
+			continue
+		}
+
+		amount, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(str, unit.suffix)), 64)
+
+		if err != nil || amount <= 0 {
+			break
+		}
+
+		return diskThreshold{bytes: int64(amount * float64(unit.size))}, nil
+	}
+
+	return diskThreshold{}, fmt.Errorf("invalid low disk threshold %q: should look like 50GB or 10%%", str)
+}
+
+// low reports whether free space is under the threshold
+// percentages can't be checked without knowing the size of the disk
+func (threshold diskThreshold) low(free, total int64) bool {
+	if threshold.percent > 0 {
+		return total > 0 && 100*float64(free)/float64(total) < threshold.percent
+	}
+
+	return free < threshold.bytes
+}
+
+func (threshold diskThreshold) String() string {
+	if threshold.percent > 0 {
+		return fmt.Sprintf("%g%%", threshold.percent)
+	}
+
+	return formatBytes(threshold.bytes)
+}
+
+// diskWatcher alerts the admin channel when root folders run low on space
+type diskWatcher struct {
+	mu        sync.Mutex
+	threshold diskThreshold
+	// low are the root folders already alerted about, keyed by backend and path
+	low map[string]bool
+}
+
+func newDiskWatcher(threshold string) (*diskWatcher, error) {
+	parsed, err := parseDiskThreshold(threshold)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &diskWatcher{threshold: parsed, low: map[string]bool{}}, nil
+}
+
+// check returns alerts for root folders that dropped below the threshold
+// and ones that recovered since the last check. Folders are only alerted
+// about once until they recover. A percentage can't be checked on a disk
+// of unknown size, so those folders are skipped rather than treated as recovered
+func (watcher *diskWatcher) check(services clients) []string {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	var alerts []string
+
+	for _, backend := range services.mediaBackends() {
+		folders, err := backend.RootFolders()
+
+		if err != nil {
+			logger.Warn("check root folders failed", "backend", backend.Name(), "error", err)
+			continue
+		}
+
+		disks, err := fetchDiskSpace(backend.API())
+
+		if err != nil {
+			logger.Warn("check disk space failed", "backend", backend.Name(), "error", err)
+		}
+
+		for _, folder := range folders {
+			var total int64
+
+			if disk, ok := diskFor(folder.path, disks); ok {
+				total = disk.TotalSpace
+			}
+
+			// the folder keeps whatever state it had until the next check
+			if watcher.threshold.percent > 0 && total == 0 {
+				continue
+			}
+
+			key := backend.Name() + ":" + folder.path
+			low := watcher.threshold.low(folder.freeSpace, total)
+
+			switch {
+			case low && !watcher.low[key]:
+				alerts = append(alerts, fmt.Sprintf("%s root folder `%s` is low on space: %s free (alerting below %s)",
+					backend.Name(),
+					folder.path,
+					formatBytes(folder.freeSpace),
+					watcher.threshold))
+			case !low && watcher.low[key]:
+				alerts = append(alerts, fmt.Sprintf("%s root folder `%s` has room again: %s free",
+					backend.Name(),
+					folder.path,
+					formatBytes(folder.freeSpace)))
+			}
+
+			watcher.low[key] = low
+		}
+	}
+
+	return alerts
+}
+
+// run checks root folders every interval and posts alerts to the admin
+// channel until stop is closed
+func (watcher *diskWatcher) run(commandList d, backends *backendState, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+
+	defer ticker.Stop()
+
+	for {
+		for _, alert := range watcher.check(backends.clients()) {
+			logger.Warn("disk space alert", "alert", alert)
+			commandList.notify(adminChannel, alert)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func showDiskSpace(commandList d, services clients) func(channelID string, args ...string) {
+	return func(channelID string, args ...string) {
+		// command: disk
+		output := ""
+
+		for _, backend := range services.mediaBackends() {
+			api := backend.API()
+
+			disks, err := fetchDiskSpace(api)
+
+			if err != nil {
+				channelLogger(channelID).Error("fetch disk space failed", "backend", api.name, "error", err)
+				output += fmt.Sprintf("**%s**: could not fetch disk space: %v\n", api.name, err)
+				continue
+			}
+
+			output += fmt.Sprintf("**%s**:\n", api.name)
+
+			for _, disk := range disks {
+				output += fmt.Sprintf("\t%s\n", disk)
+			}
+		}
+
+		commandList.send(channelID, output)
+	}
+}
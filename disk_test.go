@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDisk(t *testing.T) {
+	runCommandCases(t, "disk", []commandCase{
+		{
+			name: "both backends",
+			want: []string{"**radarr**:\n" +
+				"\t`/` 10.0 GB free of 100.0 GB (10%)\n" +
+				"\t`/movies` 500.0 GB free of 4.0 TB (12%) media\n" +
+				"**sonarr**:\n" +
+				"\t`/tv` 1.0 TB free of 4.0 TB (25%) media\n"},
+			request: "sonarr GET /api/v3/diskspace",
+		},
+		{
+			name: "one backend failing",
+			setup: func(h *harness) {
+				h.sonarr.respond("GET /api/v3/diskspace", 500, "")
+			},
+			want: []string{"**radarr**:\n" +
+				"\t`/` 10.0 GB free of 100.0 GB (10%)\n" +
+				"\t`/movies` 500.0 GB free of 4.0 TB (12%) media\n" +
+				"**sonarr**: could not fetch disk space: 500 Internal Server Error\n"},
+		},
+	})
+}
+
+func TestDiskWatcherUnknownSize(t *testing.T) {
+	h := newHarness(t)
+
+	watcher, err := newDiskWatcher("15%")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"radarr root folder `/movies` is low on space: 500.0 GB free (alerting below 15%)",
+		"radarr root folder `/kids` is low on space: 10.0 GB free (alerting below 15%)",
+	}
+
+	if alerts := watcher.check(h.services); !reflect.DeepEqual(alerts, want) {
+		t.Fatalf("first check got %q, want %q", alerts, want)
+	}
+
+	h.radarr.respond("GET /api/v3/diskspace", 500, "")
+
+	if alerts := watcher.check(h.services); len(alerts) != 0 {
+		t.Fatalf("check without disk space got %q, want no alerts", alerts)
+	}
+
+	// `/movies` grew, the disk `/kids` is on is gone
+	h.radarr.respond("GET /api/v3/diskspace", 200, `[{"path": "/movies", "freeSpace": 536870912000, "totalSpace": 1099511627776}]`)
+
+	want = []string{"radarr root folder `/movies` has room again: 500.0 GB free"}
+
+	if alerts := watcher.check(h.services); !reflect.DeepEqual(alerts, want) {
+		t.Fatalf("check after growing got %q, want %q", alerts, want)
+	}
+
+	if !watcher.low["radarr:/kids"] {
+		t.Error("`/kids` should still be low while its disk is unknown")
+	}
+}
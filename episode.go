@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	sonarr "github.com/jrudio/go-sonarr-client"
+)
+
+// episode.go checks on and searches for single episodes and seasons
+
+// episodeCodePattern matches `S02E05` or just a season `S02`
+var episodeCodePattern = regexp.MustCompile(`^[sS](\d{1,3})(?:[eE](\d{1,4}))?$`)
+
+// parseEpisodeCode returns the season and episode of `S02E05`
+// episode is -1 for codes like `S02` that only name a season
+func parseEpisodeCode(code string) (season, episode int, err error) {
+	match := episodeCodePattern.FindStringSubmatch(code)
+
+	if match == nil {
+		return 0, 0, fmt.Errorf("`%s` should look like S02E05 or S02", code)
+	}
+
+	season, _ = strconv.Atoi(match[1])
+	episode = -1
+
+	if match[2] != "" {
+		episode, _ = strconv.Atoi(match[2])
+	}
+
+	return season, episode, nil
+}
+
+// splitEpisodeArgs separates `<tvdb-id|title> S02E05` into the show and its episode code
+func splitEpisodeArgs(args []string) (show string, season, episode int, err error) {
+	if len(args) < 2 {
+		return "", 0, 0, errors.New("missing the show or the episode")
+	}
+
+	season, episode, err = parseEpisodeCode(args[len(args)-1])
+
+	show = strings.TrimSpace(strings.Join(args[:len(args)-1], " "))
+
+	return show, season, episode, err
+}
+
+// libraryShow returns the show in sonarr's library input refers to
+func libraryShow(services clients, input string) (media, error) {
+	return libraryItem(newSonarrBackend(services), "show", input)
+}
+
+// findEpisode returns an episode of a show in sonarr's library
+func findEpisode(services clients, show media, season, episode int) (sonarr.Episode, error) {
+	episodes, err := services.sonarr.GetEpisodes(show.libraryID)
+
+	if err != nil {
+		return sonarr.Episode{}, fmt.Errorf("fetch episodes from sonarr failed: %v", err)
+	}
+
+	for _, ep := range episodes {
+		if ep.SeasonNumber == season && ep.EpisodeNumber == episode {
+			return ep, nil
+		}
+	}
+
+	return sonarr.Episode{}, fmt.Errorf("`%s` has no S%02dE%02d", show.title, season, episode)
+}
+
+// episodeStatus describes whether an episode aired, was downloaded and is monitored
+func episodeStatus(ep sonarr.Episode) string {
+	var status []string
+
+	switch {
+	case ep.AirDateUTC.IsZero():
+		status = append(status, "no air date yet")
+	case ep.AirDateUTC.After(time.Now()):
+		status = append(status, "airs "+ep.AirDateUTC.Local().Format("2006-01-02"))
+	default:
+		status = append(status, "aired "+ep.AirDateUTC.Local().Format("2006-01-02"))
+	}
+
+	if ep.HasFile {
+		status = append(status, "downloaded")
+	} else {
+		status = append(status, "missing")
+	}
+
+	if ep.Monitored {
+		status = append(status, "monitored")
+	} else {
+		status = append(status, "unmonitored")
+	}
+
+	return strings.Join(status, ", ")
+}
+
+func showEpisode(commandList d, services clients) func(channelID string, args ...string) {
+	return func(channelID string, args ...string) {
+		// command: episode [search] <tvdb-id|title> S02E05
+		usage := "`episode [search] <tvdb-id|imdb-id|link|title [year]> S02E05`"
+
+		searchFor := len(args) > 0 && args[0] == "search"
+
+		if searchFor {
+			args = args[1:]
+		}
+
+		input, season, episode, err := splitEpisodeArgs(args)
+
+		if err != nil {
+			commandList.showError(channelID, fmt.Sprintf("%v: %s", err, usage))
+			return
+		}
+
+		if episode < 0 {
+			commandList.showError(channelID, fmt.Sprintf("that's a whole season, use `season search %s S%02d` instead", input, season))
+			return
+		}
+
+		show, err := libraryShow(services, input)
+
+		if err != nil {
+			commandList.showError(channelID, err.Error())
+			return
+		}
+
+		ep, err := findEpisode(services, show, season, episode)
+
+		if err != nil {
+			commandList.showError(channelID, err.Error())
+			return
+		}
+
+		name := fmt.Sprintf("`%s` S%02dE%02d", show.title, ep.SeasonNumber, ep.EpisodeNumber)
+
+		if !searchFor {
+			commandList.send(channelID, fmt.Sprintf("%s *%s* %s", name, ep.Title, episodeStatus(ep)))
+			return
+		}
+
+		command, err := services.sonarrAPI.runCommand("EpisodeSearch", map[string]interface{}{
+			"episodeIds": []int{ep.ID},
+		})
+
+		if err != nil {
+			channelLogger(channelID).Error("episode search failed", "backend", "sonarr", "error", err)
+			commandList.showError(channelID, fmt.Sprintf("sonarr could not search for %s: %v", name, err))
+			return
+		}
+
+		commandList.send(channelID, fmt.Sprintf("searching for %s (command `%d` %s)", name, command.ID, command.state()))
+	}
+}
+
+func searchSeason(commandList d, services clients) func(channelID string, args ...string) {
+	return func(channelID string, args ...string) {
+		// command: season search <tvdb-id|title> S02
+		usage := "`season search <tvdb-id|imdb-id|link|title [year]> S02`"
+
+		if len(args) == 0 || args[0] != "search" {
+			commandList.showError(channelID, usage)
+			return
+		}
+
+		input, season, episode, err := splitEpisodeArgs(args[1:])
+
+		if err != nil {
+			commandList.showError(channelID, fmt.Sprintf("%v: %s", err, usage))
+			return
+		}
+
+		if episode >= 0 {
+			commandList.showError(channelID, fmt.Sprintf("that's a single episode, use `episode search %s S%02dE%02d` instead", input, season, episode))
+			return
+		}
+
+		show, err := libraryShow(services, input)
+
+		if err != nil {
+			commandList.showError(channelID, err.Error())
+			return
+		}
+
+		name := fmt.Sprintf("`%s` season %d", show.title, season)
+
+		command, err := services.sonarrAPI.runCommand("SeasonSearch", map[string]interface{}{
+			"seriesId":     show.libraryID,
+			"seasonNumber": season,
+		})
+
+		if err != nil {
+			channelLogger(channelID).Error("season search failed", "backend", "sonarr", "error", err)
+			commandList.showError(channelID, fmt.Sprintf("sonarr could not search for %s: %v", name, err))
+			return
+		}
+
+		commandList.send(channelID, fmt.Sprintf("searching for %s (command `%d` %s)", name, command.ID, command.state()))
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import "testing"
+
+func TestEpisode(t *testing.T) {
+	runCommandCases(t, "episode", []commandCase{
+		{
+			name:    "downloaded",
+			args:    []string{"breaking", "bad", "S02E05"},
+			want:    []string{"`Breaking Bad` S02E05 *Breakage* aired 2009-04-06, downloaded, monitored"},
+			request: "sonarr GET /api/v3/episode",
+			query:   map[string]string{"seriesId": "1"},
+		},
+		{
+			name: "missing",
+			args: []string{"81189", "s5e16"},
+			want: []string{"`Breaking Bad` S05E16 *Felina* aired 2013-09-30, missing, unmonitored"},
+		},
+		{
+			name:    "search",
+			args:    []string{"search", "81189", "S02E05"},
+			want:    []string{"searching for `Breaking Bad` S02E05 (command `7` queued)"},
+			request: "sonarr POST /api/v3/command",
+			body: map[string]interface{}{
+				"name":       "EpisodeSearch",
+				"episodeIds": []interface{}{float64(105)},
+			},
+		},
+		{
+			name: "search failing",
+			args: []string{"search", "81189", "S02E05"},
+			setup: func(h *harness) {
+				h.sonarr.respond("POST /api/v3/command", 500, "")
+			},
+			want: []string{"sonarr could not search for `Breaking Bad` S02E05: 500 Internal Server Error"},
+		},
+		{
+			name: "no such episode",
+			args: []string{"81189", "S09E01"},
+			want: []string{"`Breaking Bad` has no S09E01"},
+		},
+		{
+			name: "not in library",
+			args: []string{"403245", "S01E01"},
+			want: []string{"`403245` is not in your library, `add show 403245` first"},
+		},
+		{
+			name:  "whole season",
+			args:  []string{"search", "81189", "S02"},
+			want:  []string{"that's a whole season, use `season search 81189 S02` instead"},
+			check: nothingSent("sonarr", "POST", "/api/v3/command"),
+		},
+		{
+			name: "missing episode code",
+			args: []string{"breaking", "bad"},
+			want: []string{"`bad` should look like S02E05 or S02: `episode [search] <tvdb-id|imdb-id|link|title [year]> S02E05`"},
+		},
+	})
+}
+
+func TestSeason(t *testing.T) {
+	runCommandCases(t, "season", []commandCase{
+		{
+			name:    "search",
+			args:    []string{"search", "breaking", "bad", "S03"},
+			want:    []string{"searching for `Breaking Bad` season 3 (command `7` queued)"},
+			request: "sonarr POST /api/v3/command",
+			body: map[string]interface{}{
+				"name":         "SeasonSearch",
+				"seriesId":     float64(1),
+				"seasonNumber": float64(3),
+			},
+		},
+		{
+			name: "search failing",
+			args: []string{"search", "81189", "S03"},
+			setup: func(h *harness) {
+				h.sonarr.respond("POST /api/v3/command", 500, "")
+			},
+			want: []string{"sonarr could not search for `Breaking Bad` season 3: 500 Internal Server Error"},
+		},
+		{
+			name:  "single episode",
+			args:  []string{"search", "81189", "S03E01"},
+			want:  []string{"that's a single episode, use `episode search 81189 S03E01` instead"},
+			check: nothingSent("sonarr", "POST", "/api/v3/command"),
+		},
+		{
+			name: "without search",
+			args: []string{"81189", "S03"},
+			want: []string{"`season search <tvdb-id|imdb-id|link|title [year]> S02`"},
+		},
+	})
+}
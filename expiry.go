@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// expiry.go deletes shart's replies, and the messages that asked for them,
+// once they are no longer useful
+
+// expiry ttls that aren't command names
+const (
+	expireHelp  = "help"
+	expireError = "error"
+)
+
+var (
+	messageExpiry *expiryScheduler
+	// expireAfter configures the ttls, e.g. `search=10m,help=5m,error=15m`
+	expireAfter string
+)
+
+// expiringMessages are messages in a channel due to be deleted at the same time
+type expiringMessages struct {
+	ChannelID  string    `json:"channel_id"`
+	MessageIDs []string  `json:"message_ids"`
+	At         time.Time `json:"at"`
+}
+
+// expiryScheduler deletes messages once their ttl is up
+// pending deletions are saved to disk so they survive a restart
+type expiryScheduler struct {
+	mu      sync.Mutex
+	path    string
+	ttls    map[string]time.Duration
+	pending []expiringMessages
+}
+
+// parseTTLs parses a comma separated list of `<command|help|error>=<duration>`
+func parseTTLs(str string) (map[string]time.Duration, error) {
+	ttls := map[string]time.Duration{}
+
+	for _, pair := range strings.Split(str, ",") {
+		pair = strings.TrimSpace(pair)
+
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+
+		if len(parts) != 2 {
+			return ttls, fmt.Errorf("invalid expiry %q: should look like search=10m", pair)
+		}
+
+		ttl, err := time.ParseDuration(parts[1])
+
+		if err != nil || ttl <= 0 {
+			return ttls, fmt.Errorf("invalid expiry %q: duration should look like 30s or 10m", pair)
+		}
+
+		ttls[parts[0]] = ttl
+	}
+
+	return ttls, nil
+}
+
+// newExpiryScheduler loads any deletions left over from the last run
+func newExpiryScheduler(dataDir, ttls string) (*expiryScheduler, error) {
+	parsed, err := parseTTLs(ttls)
+
+	if err != nil {
+		return nil, err
+	}
+
+	scheduler := &expiryScheduler{
+		path: filepath.Join(dataDir, "expiring.json"),
+		ttls: parsed,
+	}
+
+	contents, err := ioutil.ReadFile(scheduler.path)
+
+	if os.IsNotExist(err) {
+		return scheduler, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(contents, &scheduler.pending); err != nil {
+		return nil, fmt.Errorf("corrupt %s: %v", scheduler.path, err)
+	}
+
+	return scheduler, nil
+}
+
+// ttl returns how long the messages of an invocation should live
+// zero means they stay
+func (e *expiryScheduler) ttl(result invocation) time.Duration {
+	switch {
+	case result.failed:
+		return e.ttls[expireError]
+	case result.command == "":
+		return e.ttls[expireHelp]
+	default:
+		return e.ttls[result.command]
+	}
+}
+
+// schedule deletes the message that triggered a command along with the
+// replies it got once the command's ttl is up
+func (e *expiryScheduler) schedule(channelID, triggerID string, result invocation) error {
+	ttl := e.ttl(result)
+
+	if ttl == 0 {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.pending = append(e.pending, expiringMessages{
+		ChannelID:  channelID,
+		MessageIDs: append([]string{triggerID}, result.replyIDs...),
+		At:         time.Now().Add(ttl),
+	})
+
+	return e.save()
+}
+
+// save writes pending deletions to disk; the caller must hold e.mu
+func (e *expiryScheduler) save() error {
+	contents, err := json.Marshal(e.pending)
+
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(e.path, contents)
+}
+
+// due removes and returns deletions whose time has come
+func (e *expiryScheduler) due(now time.Time) []expiringMessages {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var due, pending []expiringMessages
+
+	for _, messages := range e.pending {
+		if now.Before(messages.At) {
+			pending = append(pending, messages)
+		} else {
+			due = append(due, messages)
+		}
+	}
+
+	if len(due) == 0 {
+		return nil
+	}
+
+	e.pending = pending
+
+	if err := e.save(); err != nil {
+		logger.Error("save expiring messages failed", "error", err)
+	}
+
+	return due
+}
+
+// run deletes expired messages every interval until stop is closed
+func (e *expiryScheduler) run(chat chatTransport, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+
+	defer ticker.Stop()
+
+	for {
+		for _, messages := range e.due(time.Now()) {
+			deleteMessages(chat, messages.ChannelID, messages.MessageIDs)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// deleteMessages removes messages in bulk, falling back to one at a time
+// when discord refuses, e.g. because one of them was already deleted
+func deleteMessages(chat chatTransport, channelID string, messageIDs []string) {
+	if err := chat.ChannelMessagesBulkDelete(channelID, messageIDs); err == nil {
+		return
+	}
+
+	for _, messageID := range messageIDs {
+		if err := chat.ChannelMessageDelete(channelID, messageID); err != nil {
+			logger.Debug("delete expired message failed",
+				"channel", channelID,
+				"message", messageID,
+				"error", err)
+		}
+	}
+}
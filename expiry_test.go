@@ -0,0 +1,134 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpirySchedule(t *testing.T) {
+	tests := []struct {
+		name   string
+		result invocation
+		// want is the ttl the messages get, zero when they stay
+		want time.Duration
+	}{
+		{"command", invocation{command: "search", replyIDs: []string{"2", "3"}}, 10 * time.Minute},
+		{"failed command", invocation{command: "search", failed: true, replyIDs: []string{"2"}}, 15 * time.Minute},
+		{"help", invocation{replyIDs: []string{"2"}}, 5 * time.Minute},
+		{"command without a ttl", invocation{command: "status", replyIDs: []string{"2"}}, 0},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			scheduler, err := newExpiryScheduler(t.TempDir(), "search=10m,help=5m,error=15m")
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			before := time.Now()
+
+			if err := scheduler.schedule(testChannel, "1", tt.result); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.want == 0 {
+				if len(scheduler.pending) != 0 {
+					t.Errorf("scheduled %+v, want nothing", scheduler.pending)
+				}
+
+				return
+			}
+
+			if len(scheduler.pending) != 1 {
+				t.Fatalf("scheduled %+v, want one deletion", scheduler.pending)
+			}
+
+			pending := scheduler.pending[0]
+			wantIDs := append([]string{"1"}, tt.result.replyIDs...)
+
+			if pending.ChannelID != testChannel || !reflect.DeepEqual(pending.MessageIDs, wantIDs) {
+				t.Errorf("scheduled %+v, want %v in %s", pending, wantIDs, testChannel)
+			}
+
+			if at := pending.At.Sub(before); at < tt.want || at > tt.want+time.Minute {
+				t.Errorf("deleted after %v, want %v", at, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpiryRunDeletesDue(t *testing.T) {
+	scheduler, err := newExpiryScheduler(t.TempDir(), "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scheduler.pending = []expiringMessages{
+		{ChannelID: testChannel, MessageIDs: []string{"1", "2"}, At: time.Now().Add(-time.Second)},
+		{ChannelID: testChannel, MessageIDs: []string{"3", "4"}, At: time.Now().Add(time.Hour)},
+	}
+
+	chat := newFakeChat()
+	stop := make(chan struct{})
+
+	// a closed stop still lets run go through what's due once
+	close(stop)
+
+	scheduler.run(chat, time.Hour, stop)
+
+	if want := []string{"1", "2"}; !reflect.DeepEqual(chat.deleted, want) {
+		t.Errorf("deleted %v, want %v", chat.deleted, want)
+	}
+
+	if len(scheduler.pending) != 1 || scheduler.pending[0].MessageIDs[0] != "3" {
+		t.Errorf("still pending %+v, want the messages not due yet", scheduler.pending)
+	}
+}
+
+func TestExpirySurvivesRestart(t *testing.T) {
+	dataDir := t.TempDir()
+
+	scheduler, err := newExpiryScheduler(dataDir, "search=10m")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scheduler.schedule(testChannel, "1", invocation{command: "search", replyIDs: []string{"2"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, err := newExpiryScheduler(dataDir, "search=10m")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(restarted.pending) != 1 {
+		t.Fatalf("loaded %+v from data/expiring.json, want one deletion", restarted.pending)
+	}
+
+	loaded, saved := restarted.pending[0], scheduler.pending[0]
+
+	if loaded.ChannelID != saved.ChannelID || !reflect.DeepEqual(loaded.MessageIDs, saved.MessageIDs) || !loaded.At.Equal(saved.At) {
+		t.Errorf("loaded %+v, want %+v", loaded, saved)
+	}
+
+	// deletions that were due are gone from disk too
+	restarted.due(saved.At)
+
+	again, err := newExpiryScheduler(dataDir, "search=10m")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(again.pending) != 0 {
+		t.Errorf("loaded %+v after the deletion was due, want nothing", again.pending)
+	}
+}
@@ -0,0 +1,763 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// fakes_test.go has the fake radarr, sonarr and discord every command test runs against
+
+func TestMain(m *testing.M) {
+	// commands log failures on purpose, keep test output readable
+	logger = slog.New(slog.NewTextHandler(ioutil.Discard, nil))
+
+	// dates are shown in local time
+	time.Local = time.UTC
+
+	os.Exit(m.Run())
+}
+
+// fakeResponse is what a fake backend answers a request with
+type fakeResponse struct {
+	status int
+	body   string
+	// wait holds the response back until it's closed
+	wait chan struct{}
+}
+
+// fakeRequest is a request a fake backend received
+type fakeRequest struct {
+	method string
+	path   string
+	query  string
+	apiKey string
+	body   string
+}
+
+// fakeArr is a radarr or sonarr that answers with responses recorded in
+// testdata/<name>. Requests are matched by method, path and query first and
+// then by method and path alone.
+//
+// A version 2 fake only answers the old /api. Its requests are matched and
+// remembered under the v3 path that answers the same thing, so overrides and
+// assertions name every request the v3 way whichever version a case runs against
+type fakeArr struct {
+	t       *testing.T
+	name    string
+	version int
+	server  *httptest.Server
+
+	mu        sync.Mutex
+	overrides map[string]fakeResponse
+	requests  []fakeRequest
+}
+
+// recordings map requests to the files in testdata/<backend> holding the answer
+var recordings = map[string]map[string]string{
+	"radarr": {
+		"GET /api/v3/system/status":                   "system_status.json",
+		"GET /api/v3/health":                          "health.json",
+		"GET /api/v3/movie":                           "movie.json",
+		"GET /api/v3/movie/1":                         "movie_1.json",
+		"POST /api/v3/movie":                          "movie_added.json",
+		"GET /api/v3/movie/lookup?term=sicario":       "lookup_sicario.json",
+		"GET /api/v3/movie/lookup?term=dune":          "lookup_dune.json",
+		"GET /api/v3/movie/lookup/tmdb?tmdbId=273481": "lookup_tmdb_273481.json",
+		"GET /api/v3/movie/lookup/tmdb?tmdbId=400535": "lookup_tmdb_400535.json",
+		"GET /api/v3/importlist/movie":                "importlist_movie.json",
+		"GET /api/v3/qualityprofile":                  "qualityprofile.json",
+		"GET /api/v3/rootfolder":                      "rootfolder.json",
+		"GET /api/v3/diskspace":                       "diskspace.json",
+		"GET /api/v3/tag":                             "tag.json",
+		"POST /api/v3/tag":                            "tag_created.json",
+		"GET /api/v3/wanted/missing":                  "wanted_missing.json",
+		"GET /api/v3/wanted/cutoff":                   "wanted_cutoff.json",
+		"GET /api/v3/release":                         "release.json",
+		"POST /api/v3/release":                        "release_grabbed.json",
+		"POST /api/v3/command":                        "command.json",
+		"GET /api/v3/command/42":                      "command_42.json",
+	},
+	"sonarr": {
+		"GET /api/v3/system/status":                    "system_status.json",
+		"GET /api/v3/health":                           "health.json",
+		"GET /api/v3/series":                           "series.json",
+		"POST /api/v3/series":                          "series_added.json",
+		"GET /api/v3/series/lookup?term=breaking+bad":  "lookup_breaking_bad.json",
+		"GET /api/v3/series/lookup?term=tvdb%3A81189":  "lookup_tvdb_81189.json",
+		"GET /api/v3/series/lookup?term=tvdb%3A403245": "lookup_tvdb_403245.json",
+		"GET /api/v3/episode?seriesId=1":               "episode_1.json",
+		"GET /api/v3/episodefile?seriesId=1":           "episodefile_1.json",
+		"GET /api/v3/qualityprofile":                   "qualityprofile.json",
+		"GET /api/v3/languageprofile":                  "languageprofile.json",
+		"GET /api/v3/rootfolder":                       "rootfolder.json",
+		"GET /api/v3/diskspace":                        "diskspace.json",
+		"GET /api/v3/tag":                              "tag.json",
+		"POST /api/v3/tag":                             "tag_created.json",
+		"GET /api/v3/wanted/missing":                   "wanted_missing.json",
+		"GET /api/v3/release":                          "release.json",
+		"POST /api/v3/release":                         "release_grabbed.json",
+		"POST /api/v3/command":                         "command.json",
+		"GET /api/v3/command/7":                        "command_7.json",
+		"GET /api/v3/series/lookup?term=the+bear":      "lookup_the_bear.json",
+	},
+}
+
+// legacyRecordings are the answers a version 2 fake gives that differ from
+// v3's, keyed by the v2 path. Anything else is answered with the v3 recording
+var legacyRecordings = map[string]map[string]string{
+	"radarr": {
+		"GET /api/system/status":                   "v2/system_status.json",
+		"GET /api/profile":                         "v2/profile.json",
+		"GET /api/movie":                           "v2/movie.json",
+		"GET /api/movie/lookup?term=sicario":       "v2/lookup_sicario.json",
+		"GET /api/movie/lookup?term=dune":          "v2/lookup_dune.json",
+		"GET /api/movie/lookup/tmdb?tmdbId=273481": "v2/lookup_tmdb_273481.json",
+		"GET /api/movie/lookup/tmdb?tmdbId=400535": "v2/lookup_tmdb_400535.json",
+	},
+	"sonarr": {
+		"GET /api/system/status": "v2/system_status.json",
+		"GET /api/profile":       "v2/profile.json",
+		"GET /api/series":        "v2/series.json",
+	},
+}
+
+// apiVersions are the radarr and sonarr api versions command cases run against
+var apiVersions = []int{3, 2}
+
+func newFakeArr(t *testing.T, name string, version int) *fakeArr {
+	fake := &fakeArr{t: t, name: name, version: version, overrides: map[string]fakeResponse{}}
+
+	fake.server = httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+
+	t.Cleanup(fake.server.Close)
+
+	return fake
+}
+
+// respond makes the fake answer `METHOD /path` or `METHOD /path?query` with status and body
+func (fake *fakeArr) respond(request string, status int, body string) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	fake.overrides[request] = fakeResponse{status: status, body: body}
+}
+
+// stall makes the fake hold back its answer to request until release is called
+func (fake *fakeArr) stall(request string, status int, body string) (release func()) {
+	wait := make(chan struct{})
+
+	fake.mu.Lock()
+	fake.overrides[request] = fakeResponse{status: status, body: body, wait: wait}
+	fake.mu.Unlock()
+
+	return func() { close(wait) }
+}
+
+// movedPaths are the v2 paths whose v3 equivalent isn't at the same place
+var movedPaths = map[string]string{
+	"/api/profile":                         "/api/v3/qualityprofile",
+	"/api/movies/discover/recommendations": "/api/v3/importlist/movie",
+}
+
+// v3Path returns the v3 path that answers the same request as a v2 path
+func v3Path(path string) string {
+	if moved, ok := movedPaths[path]; ok {
+		return moved
+	}
+
+	return v3APIRoot + strings.TrimPrefix(path, legacyAPIRoot)
+}
+
+// requestKeys are the keys a request is matched by, most specific first
+func requestKeys(method, path string, query url.Values) []string {
+	keys := []string{method + " " + path}
+
+	if encoded := query.Encode(); encoded != "" {
+		keys = append([]string{method + " " + path + "?" + encoded}, keys...)
+	}
+
+	return keys
+}
+
+// recorded returns the file the first of keys is recorded in
+func recorded(files map[string]string, keys []string) (string, bool) {
+	for _, key := range keys {
+		if file, ok := files[key]; ok {
+			return file, true
+		}
+	}
+
+	return "", false
+}
+
+func (fake *fakeArr) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	// the sonarr client sends its key in the query
+	query := r.URL.Query()
+	apiKey := r.Header.Get("X-Api-Key")
+
+	if apiKey == "" {
+		apiKey = query.Get("apikey")
+	}
+
+	query.Del("apikey")
+
+	path := r.URL.Path
+	// legacyKeys match the v2 request against the recordings only v2 has
+	var legacyKeys []string
+
+	// v2 servers don't have the v3 api
+	missing := fake.version < 3 && (!strings.HasPrefix(path, legacyAPIRoot+"/") || strings.HasPrefix(path, v3APIRoot+"/"))
+
+	if fake.version < 3 && !missing {
+		legacyKeys = requestKeys(r.Method, path, query)
+		path = v3Path(path)
+	}
+
+	fake.mu.Lock()
+
+	fake.requests = append(fake.requests, fakeRequest{
+		method: r.Method,
+		path:   path,
+		query:  query.Encode(),
+		apiKey: apiKey,
+		body:   string(body),
+	})
+
+	if missing {
+		fake.mu.Unlock()
+		http.NotFound(w, r)
+		return
+	}
+
+	keys := requestKeys(r.Method, path, query)
+
+	var response *fakeResponse
+
+	for _, key := range keys {
+		if override, ok := fake.overrides[key]; ok {
+			response = &override
+			break
+		}
+	}
+
+	if response == nil {
+		file, ok := recorded(legacyRecordings[fake.name], legacyKeys)
+
+		if !ok {
+			file, ok = recorded(recordings[fake.name], keys)
+		}
+
+		if ok {
+			answer, err := ioutil.ReadFile(filepath.Join("testdata", fake.name, file))
+
+			if err != nil {
+				fake.t.Errorf("%s: read recording %s: %v", fake.name, file, err)
+			}
+
+			status := http.StatusOK
+
+			if r.Method == "POST" {
+				status = http.StatusCreated
+			}
+
+			response = &fakeResponse{status: status, body: string(answer)}
+		}
+	}
+
+	fake.mu.Unlock()
+
+	if response == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if response.wait != nil {
+		<-response.wait
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(response.status)
+	io.WriteString(w, response.body)
+}
+
+// sent returns the requests made with method to path, e.g. `POST /api/v3/movie`
+func (fake *fakeArr) sent(method, path string) []fakeRequest {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	var matching []fakeRequest
+
+	for _, request := range fake.requests {
+		if request.method == method && request.path == path {
+			matching = append(matching, request)
+		}
+	}
+
+	return matching
+}
+
+// forget drops the requests made so far, e.g. the ones detecting the api version
+func (fake *fakeArr) forget() {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	fake.requests = nil
+}
+
+// all returns every request made since the fake started or last forgot
+func (fake *fakeArr) all() []fakeRequest {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	return append([]fakeRequest(nil), fake.requests...)
+}
+
+// fakeMessage is something shart posted to a channel
+type fakeMessage struct {
+	id        string
+	channelID string
+	content   string
+	// file is the name of an uploaded file
+	file     string
+	fileBody string
+}
+
+// fakeChat is a chatTransport that remembers what was sent and deleted.
+// history is what ChannelMessages pages through, newest first
+type fakeChat struct {
+	mu      sync.Mutex
+	nextID  int
+	sent    []fakeMessage
+	history map[string][]*discordgo.Message
+	deleted []string
+}
+
+const fakeBotID = "900"
+
+func newFakeChat() *fakeChat {
+	return &fakeChat{nextID: 1000, history: map[string][]*discordgo.Message{}}
+}
+
+func (chat *fakeChat) BotID() string {
+	return fakeBotID
+}
+
+func (chat *fakeChat) post(message fakeMessage) *discordgo.Message {
+	chat.mu.Lock()
+	defer chat.mu.Unlock()
+
+	chat.nextID++
+	message.id = strconv.Itoa(chat.nextID)
+	chat.sent = append(chat.sent, message)
+
+	return &discordgo.Message{ID: message.id, ChannelID: message.channelID, Content: message.content}
+}
+
+func (chat *fakeChat) ChannelMessageSend(channelID, content string) (*discordgo.Message, error) {
+	return chat.post(fakeMessage{channelID: channelID, content: content}), nil
+}
+
+func (chat *fakeChat) ChannelFileSendWithMessage(channelID, content, name string, r io.Reader) (*discordgo.Message, error) {
+	body, err := ioutil.ReadAll(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return chat.post(fakeMessage{channelID: channelID, content: content, file: name, fileBody: string(body)}), nil
+}
+
+func (chat *fakeChat) ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string) ([]*discordgo.Message, error) {
+	chat.mu.Lock()
+	defer chat.mu.Unlock()
+
+	history := chat.history[channelID]
+	start := 0
+
+	if beforeID != "" {
+		start = len(history)
+
+		for i, message := range history {
+			if message.ID == beforeID {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	end := start + limit
+
+	if end > len(history) {
+		end = len(history)
+	}
+
+	return history[start:end], nil
+}
+
+func (chat *fakeChat) ChannelMessagesBulkDelete(channelID string, messages []string) error {
+	chat.mu.Lock()
+	defer chat.mu.Unlock()
+
+	chat.deleted = append(chat.deleted, messages...)
+
+	return nil
+}
+
+func (chat *fakeChat) ChannelMessageDelete(channelID, messageID string) error {
+	chat.mu.Lock()
+	defer chat.mu.Unlock()
+
+	chat.deleted = append(chat.deleted, messageID)
+
+	return nil
+}
+
+// messages returns what was posted to a channel
+func (chat *fakeChat) messages(channelID string) []string {
+	chat.mu.Lock()
+	defer chat.mu.Unlock()
+
+	var contents []string
+
+	for _, message := range chat.sent {
+		if message.channelID == channelID {
+			contents = append(contents, message.content)
+		}
+	}
+
+	return contents
+}
+
+// replies returns the messages with the given ids in the order they were sent
+func (chat *fakeChat) replies(ids []string) []string {
+	chat.mu.Lock()
+	defer chat.mu.Unlock()
+
+	wanted := map[string]bool{}
+
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	var contents []string
+
+	for _, message := range chat.sent {
+		if wanted[message.id] {
+			contents = append(contents, message.content)
+		}
+	}
+
+	return contents
+}
+
+// file returns the last file uploaded to a channel
+func (chat *fakeChat) file(channelID string) (fakeMessage, bool) {
+	chat.mu.Lock()
+	defer chat.mu.Unlock()
+
+	for i := len(chat.sent) - 1; i >= 0; i-- {
+		if message := chat.sent[i]; message.channelID == channelID && message.file != "" {
+			return message, true
+		}
+	}
+
+	return fakeMessage{}, false
+}
+
+// testChannel is the channel commands run in unless a test says otherwise
+const testChannel = "100"
+
+// harness is shart wired up to a fake radarr, sonarr and discord
+type harness struct {
+	t        *testing.T
+	version  int
+	radarr   *fakeArr
+	sonarr   *fakeArr
+	chat     *fakeChat
+	backends *backendState
+	services clients
+	commands d
+	// triggers numbers the messages commands answer
+	triggers int64
+}
+
+// newHarness starts a v3 fake radarr and sonarr, detects their api versions
+// the way main does and registers every command against a fake discord
+func newHarness(t *testing.T) *harness {
+	return newHarnessFor(t, 3)
+}
+
+// newHarnessFor is newHarness with radarr and sonarr answering api version
+func newHarnessFor(t *testing.T, version int) *harness {
+	h := &harness{
+		t:       t,
+		version: version,
+		radarr:  newFakeArr(t, "radarr", version),
+		sonarr:  newFakeArr(t, "sonarr", version),
+		chat:    newFakeChat(),
+	}
+
+	services, err := initializeClients(serviceCredentials{
+		radarr: radarrCredentials{url: h.radarr.server.URL, apiKey: "radarr-key"},
+		sonarr: sonarrCredentials{url: h.sonarr.server.URL, apiKey: "sonarr-key"},
+	})
+
+	if err != nil {
+		t.Fatalf("initialize clients: %v", err)
+	}
+
+	h.backends = newBackendState(services, checkBackends(services))
+	h.services = h.backends.clients()
+
+	resetState(t)
+
+	h.commands = addCommands(newDiscord(h.chat), h.backends)
+
+	return h
+}
+
+// resetState puts the package level state commands share back the way
+// shart starts so tests can't leak into each other
+func resetState(t *testing.T) {
+	defaultRadarrPath = ""
+	defaultRadarrQualityID = 0
+	defaultSonarrPath = ""
+	defaultSonarrQualityID = 0
+	seriesFolderFormat = "{Title}"
+
+	if err := loadShowDefaults(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	listedReleases.Lock()
+	listedReleases.byChannel = map[string]releaseListing{}
+	listedReleases.Unlock()
+
+	auditTrail = newAuditLog(t.TempDir())
+}
+
+// run executes a command in testChannel and returns what it replied.
+// Notifications posted in the background aren't replies
+func (h *harness) run(command string, args ...string) []string {
+	trigger := strconv.FormatInt(atomic.AddInt64(&h.triggers, 1), 10)
+
+	current := h.commands.track(trigger, func(commandList commands) {
+		commandList.execute(testChannel, command, args...)
+	})
+
+	return h.chat.replies(current.replyIDs)
+}
+
+// waitForMessages waits for background work to post count messages to a channel
+func (h *harness) waitForMessages(channelID string, count int) []string {
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		messages := h.chat.messages(channelID)
+
+		if len(messages) >= count || time.Now().After(deadline) {
+			return messages
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// commandCase is a command, what it should reply and the request it should send
+type commandCase struct {
+	name  string
+	args  []string
+	setup func(h *harness)
+	want  []string
+	// request is the request the command should have made, e.g. `radarr POST /api/v3/movie`
+	request string
+	// query and body are compared with the last request made to request.
+	// Only the keys listed are checked
+	query map[string]string
+	body  map[string]interface{}
+	// check runs extra assertions after the command
+	check func(t *testing.T, h *harness)
+	// legacy is what's different against the v2 api. Its setup, want, request,
+	// query and check replace the case's when set and its body keys are merged
+	// in. A different request drops the case's query and body
+	legacy *commandCase
+}
+
+// forVersion returns the case as it runs against api version
+func (tc commandCase) forVersion(version int) commandCase {
+	if version >= 3 || tc.legacy == nil {
+		return tc
+	}
+
+	legacy := tc.legacy
+
+	if legacy.setup != nil {
+		tc.setup = legacy.setup
+	}
+
+	if legacy.want != nil {
+		tc.want = legacy.want
+	}
+
+	if legacy.request != "" {
+		tc.request = legacy.request
+		tc.query = nil
+		tc.body = nil
+	}
+
+	if legacy.query != nil {
+		tc.query = legacy.query
+	}
+
+	if legacy.check != nil {
+		tc.check = legacy.check
+	}
+
+	if legacy.body != nil {
+		body := map[string]interface{}{}
+
+		for key, value := range tc.body {
+			body[key] = value
+		}
+
+		for key, value := range legacy.body {
+			body[key] = value
+		}
+
+		tc.body = body
+	}
+
+	return tc
+}
+
+// runCommandCases runs each case against its own fake radarr, sonarr and
+// discord, once for every api version in apiVersions
+func runCommandCases(t *testing.T, command string, cases []commandCase) {
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			for _, version := range apiVersions {
+				version := version
+
+				t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+					runCommandCase(t, version, command, tc.forVersion(version))
+				})
+			}
+		})
+	}
+}
+
+func runCommandCase(t *testing.T, version int, command string, tc commandCase) {
+	h := newHarnessFor(t, version)
+
+	if tc.setup != nil {
+		tc.setup(h)
+	}
+
+	got := h.run(command, tc.args...)
+
+	// nil leaves checking the replies to check
+	if tc.want != nil && !reflect.DeepEqual(got, tc.want) {
+		t.Errorf("%s %s replied\n%q\nwant\n%q", command, strings.Join(tc.args, " "), got, tc.want)
+	}
+
+	if tc.request != "" {
+		h.assertRequest(tc.request, tc.query, tc.body)
+	}
+
+	if tc.check != nil {
+		tc.check(t, h)
+	}
+}
+
+// assertRequest checks the last request like `radarr POST /api/v3/movie` sent to radarr or sonarr
+func (h *harness) assertRequest(request string, query map[string]string, body map[string]interface{}) {
+	h.t.Helper()
+
+	parts := strings.SplitN(request, " ", 3)
+
+	if len(parts) != 3 {
+		h.t.Fatalf("request should look like `radarr POST /api/v3/movie`, got %q", request)
+	}
+
+	fake := h.radarr
+
+	if parts[0] == "sonarr" {
+		fake = h.sonarr
+	}
+
+	sent := fake.sent(parts[1], parts[2])
+
+	if len(sent) == 0 {
+		h.t.Fatalf("%s was never sent", request)
+	}
+
+	last := sent[len(sent)-1]
+
+	if last.apiKey != fake.name+"-key" {
+		h.t.Errorf("%s sent api key %q", request, last.apiKey)
+	}
+
+	if len(query) > 0 {
+		values, err := url.ParseQuery(last.query)
+
+		if err != nil {
+			h.t.Fatalf("%s query %q: %v", request, last.query, err)
+		}
+
+		for key, want := range query {
+			if got := values.Get(key); got != want {
+				h.t.Errorf("%s query %s = %q, want %q", request, key, got, want)
+			}
+		}
+	}
+
+	if len(body) > 0 {
+		var decoded map[string]interface{}
+
+		if err := json.Unmarshal([]byte(last.body), &decoded); err != nil {
+			h.t.Fatalf("%s body %q: %v", request, last.body, err)
+		}
+
+		for key, want := range body {
+			if got := lookupJSON(decoded, key); !reflect.DeepEqual(got, want) {
+				h.t.Errorf("%s body %s = %#v, want %#v", request, key, got, want)
+			}
+		}
+	}
+}
+
+// lookupJSON follows a dotted path like `addOptions.searchForMovie` into decoded json
+func lookupJSON(value interface{}, path string) interface{} {
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+
+		if !ok {
+			return nil
+		}
+
+		value = object[key]
+	}
+
+	return value
+}
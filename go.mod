@@ -1,10 +1,15 @@
 module github.com/jrudio/shart
 
+go 1.27.1
+
 require (
 	github.com/BurntSushi/toml v0.3.1
 	github.com/bwmarrin/discordgo v0.18.0
-	github.com/gorilla/websocket v1.2.0 // indirect
 	github.com/jrudio/go-radarr-client v0.0.0-20180808030014-8c6eeb33f4b4
 	github.com/jrudio/go-sonarr-client v0.0.0-20180729192042-ec124ce2d81e
+)
+
+require (
+	github.com/gorilla/websocket v1.2.0 // indirect
 	golang.org/x/crypto v0.0.0-20180723164146-c126467f60eb // indirect
 )
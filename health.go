@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// health.go reports how radarr and sonarr are doing and tells admins when that changes
+
+// healthCheckInterval is how often radarr and sonarr are asked about their health
+var healthCheckInterval time.Duration
+
+// uptime formats how long ago a server started
+func uptime(startTime string, now time.Time) string {
+	started, err := time.Parse(time.RFC3339, startTime)
+
+	if err != nil {
+		return "unknown"
+	}
+
+	elapsed := now.Sub(started)
+
+	days := int(elapsed.Hours()) / 24
+	hours := int(elapsed.Hours()) % 24
+	minutes := int(elapsed.Minutes()) % 60
+
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh", days, hours)
+	}
+
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
+
+// backendReport describes the version, uptime and health of a backend
+func backendReport(api arrAPI) string {
+	status, err := api.systemStatus()
+
+	if err != nil {
+		return fmt.Sprintf("**%s** is unreachable: %v\n", api.name, err)
+	}
+
+	output := fmt.Sprintf("**%s** `%s` (%s branch) up %s\n\tstarted from `%s`\n",
+		api.name,
+		status.Version,
+		status.Branch,
+		uptime(status.StartTime, time.Now()),
+		status.StartupPath)
+
+	checks, err := api.health()
+
+	if err != nil {
+		return output + fmt.Sprintf("\tcould not fetch health: %v\n", err)
+	}
+
+	if len(checks) == 0 {
+		return output + "\tno health issues\n"
+	}
+
+	for _, check := range checks {
+		output += fmt.Sprintf("\t%s: %s\n", check.Type, check.Message)
+	}
+
+	return output
+}
+
+func showBackendStatus(commandList d, services clients) func(channelID string) {
+	return func(channelID string) {
+		output := ""
+
+		for _, backend := range services.mediaBackends() {
+			output += backendReport(backend.API())
+		}
+
+		commandList.send(channelID, output)
+	}
+}
+
+// healthWatcher tells the admin channel about new health issues and resolved ones
+type healthWatcher struct {
+	mu sync.Mutex
+	// issues are the issues already reported, keyed by backend, source and message
+	issues map[string]string
+}
+
+func newHealthWatcher() *healthWatcher {
+	return &healthWatcher{issues: map[string]string{}}
+}
+
+// check returns alerts for issues that appeared or went away since the last check
+func (watcher *healthWatcher) check(services clients) []string {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	current := map[string]string{}
+
+	for _, backend := range services.mediaBackends() {
+		api := backend.API()
+
+		checks, err := api.health()
+
+		if err != nil {
+			current[api.name+":unreachable"] = fmt.Sprintf("%s is unreachable: %v", api.name, err)
+			continue
+		}
+
+		for _, check := range checks {
+			key := api.name + ":" + check.Source + ":" + check.Message
+
+			current[key] = fmt.Sprintf("%s %s: %s", api.name, check.Type, check.Message)
+		}
+	}
+
+	var alerts []string
+
+	for key, issue := range current {
+		if _, ok := watcher.issues[key]; !ok {
+			alerts = append(alerts, issue)
+		}
+	}
+
+	for key, issue := range watcher.issues {
+		if _, ok := current[key]; !ok {
+			alerts = append(alerts, "resolved: "+issue)
+		}
+	}
+
+	// map order is random, keep alerts stable
+	sort.Strings(alerts)
+
+	watcher.issues = current
+
+	return alerts
+}
+
+// run checks backend health every interval and posts changes to the admin
+// channel until stop is closed
+func (watcher *healthWatcher) run(commandList d, backends *backendState, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+
+	defer ticker.Stop()
+
+	for {
+		for _, alert := range watcher.check(backends.clients()) {
+			logger.Warn("backend health changed", "alert", alert)
+			commandList.notify(adminChannel, alert)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
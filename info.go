@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// info.go shows everything radarr and sonarr know about a single movie or show
+
+// maxOverviewLen leaves room in discord's 2000 character limit for the rest of the details
+const maxOverviewLen = 600
+
+// maxInfoLen is as long as a reply gets before rows of the breakdown are
+// left off, leaving room under discord's 2000 character limit to say so
+const maxInfoLen = 1900
+
+// movieDetails are fields radarr only returns for movies in its library
+type movieDetails struct {
+	Certification string `json:"certification"`
+	Path          string `json:"path"`
+	MovieFile     *struct {
+		RelativePath string `json:"relativePath"`
+		Size         int64  `json:"size"`
+		Quality      struct {
+			Quality struct {
+				Name string `json:"name"`
+			} `json:"quality"`
+		} `json:"quality"`
+	} `json:"movieFile"`
+}
+
+// mediaInfo is what `info` shows about a piece of media
+type mediaInfo struct {
+	media
+	// about are lines describing the media itself, like its genres
+	about []string
+	// inLibrary is false for media that hasn't been added
+	inLibrary bool
+	// libraryErr is why the library couldn't be checked
+	libraryErr error
+	// library are lines about the media in the library, like its path
+	library []string
+	// breakdown is a table with a row per part of the media, like a show's
+	// seasons. The first row is the header
+	breakdown []string
+	// breakdownOf names the rows, e.g. seasons
+	breakdownOf string
+}
+
+func (info mediaInfo) String() string {
+	output := fmt.Sprintf("**%s (%d)** `%d`\n", info.title, info.year, info.id)
+	output += truncate(info.overview, maxOverviewLen) + "\n\n"
+
+	for _, line := range info.about {
+		output += line + "\n"
+	}
+
+	switch {
+	case info.libraryErr != nil:
+		return output + fmt.Sprintf("library: unknown, %v\n", info.libraryErr)
+	case !info.inLibrary:
+		return output + "library: not added\n"
+	}
+
+	output += fmt.Sprintf("library: %s\n", info.status)
+
+	for _, line := range info.library {
+		output += line + "\n"
+	}
+
+	if len(info.breakdown) == 0 {
+		return output
+	}
+
+	output += "```\n" + info.breakdown[0] + "\n"
+
+	rows := info.breakdown[1:]
+
+	for i, row := range rows {
+		if len(output)+len(row) > maxInfoLen {
+			output += fmt.Sprintf("... %d more %s\n", len(rows)-i, info.breakdownOf)
+			break
+		}
+
+		output += row + "\n"
+	}
+
+	return output + "```"
+}
+
+func truncate(str string, length int) string {
+	runes := []rune(str)
+
+	if len(runes) <= length {
+		return str
+	}
+
+	return strings.TrimSpace(string(runes[:length])) + "..."
+}
+
+func (backend radarrBackend) Info(tmdbID int) (mediaInfo, error) {
+	movie, err := backend.services.radarr.GetMovie(tmdbID)
+
+	if err != nil {
+		return mediaInfo{}, fmt.Errorf("failed fetching movie: %v", err)
+	}
+
+	info := mediaInfo{
+		media: movieMedia(movie),
+		about: []string{
+			fmt.Sprintf("genres: %s", strings.Join(movie.Genres, ", ")),
+			fmt.Sprintf("runtime: %d minutes", movie.Runtime),
+			fmt.Sprintf("rating: %.1f (%d votes)", movie.Ratings.Value, movie.Ratings.Votes),
+			fmt.Sprintf("studio: %s", movie.Studio),
+			fmt.Sprintf("status: %s", movie.Status),
+		},
+	}
+
+	library, err := backend.Library()
+
+	if err != nil {
+		logger.Warn("fetch movie library failed", "backend", "radarr", "error", err)
+		info.libraryErr = err
+		return info, nil
+	}
+
+	libraryMovie, ok := libraryByID(library)[tmdbID]
+
+	if !ok {
+		return info, nil
+	}
+
+	info.inLibrary = true
+	info.status = libraryMovie.status
+
+	var details movieDetails
+
+	if err := backend.services.radarrAPI.get(fmt.Sprintf("/movie/%d", libraryMovie.libraryID), nil, &details); err != nil {
+		logger.Warn("fetch movie details failed", "backend", "radarr", "error", err)
+	}
+
+	if details.Certification != "" {
+		info.library = append(info.library, fmt.Sprintf("certification: %s", details.Certification))
+	}
+
+	info.library = append(info.library, fmt.Sprintf("path: `%s`", libraryMovie.path))
+
+	if file := details.MovieFile; file != nil {
+		info.library = append(info.library, fmt.Sprintf("file: `%s` %s, %s",
+			file.RelativePath,
+			formatBytes(file.Size),
+			file.Quality.Quality.Name))
+	}
+
+	return info, nil
+}
+
+func (backend sonarrBackend) Info(tvdbID int) (mediaInfo, error) {
+	show, err := backend.services.sonarr.GetSeriesFromTVDB(tvdbID)
+
+	if err != nil {
+		return mediaInfo{}, fmt.Errorf("failed fetching show: %v", err)
+	}
+
+	info := mediaInfo{
+		media: media{
+			id:       show.TvdbID,
+			title:    show.Title,
+			year:     show.Year,
+			overview: show.Overview,
+		},
+		about: []string{
+			fmt.Sprintf("genres: %s", strings.Join(show.Genres, ", ")),
+			fmt.Sprintf("runtime: %d minutes", show.Runtime),
+			fmt.Sprintf("certification: %s", show.Certification),
+			fmt.Sprintf("rating: %.1f (%d votes)", show.Ratings.Value, show.Ratings.Votes),
+			fmt.Sprintf("network: %s", show.Network),
+			fmt.Sprintf("status: %s", show.Status),
+		},
+		breakdownOf: "seasons",
+	}
+
+	library, err := backend.Library()
+
+	if err != nil {
+		logger.Warn("fetch show library failed", "backend", "sonarr", "error", err)
+		info.libraryErr = err
+		return info, nil
+	}
+
+	libraryShow, ok := libraryByID(library)[tvdbID]
+
+	if !ok {
+		return info, nil
+	}
+
+	info.inLibrary = true
+	info.status = libraryShow.status
+	info.library = []string{
+		fmt.Sprintf("path: `%s`", libraryShow.path),
+		fmt.Sprintf("size: %s", formatBytes(libraryShow.size)),
+	}
+
+	files, err := backend.services.sonarr.GetEpisodeFiles(libraryShow.libraryID)
+
+	if err != nil {
+		logger.Warn("fetch episode files failed", "backend", "sonarr", "error", err)
+	}
+
+	// summarize qualities like `HDTV-720p x20, WEBDL-1080p x3`
+	qualities := map[string]int{}
+
+	for _, file := range files {
+		qualities[file.Quality.Quality.Name]++
+	}
+
+	if len(qualities) > 0 {
+		var summary []string
+
+		for name, count := range qualities {
+			summary = append(summary, fmt.Sprintf("%s x%d", name, count))
+		}
+
+		sort.Strings(summary)
+
+		info.library = append(info.library, fmt.Sprintf("quality: %s", strings.Join(summary, ", ")))
+	}
+
+	info.breakdown = []string{"season  files  episodes"}
+
+	for _, season := range libraryShow.seasons {
+		info.breakdown = append(info.breakdown, fmt.Sprintf("%6d  %5d  %8d",
+			season.number,
+			season.files,
+			season.episodes))
+	}
+
+	return info, nil
+}
+
+func showInfo(commandList d, services clients) func(channelID string, args ...string) {
+	return func(channelID string, args ...string) {
+		// command: info <movie|show> <id|imdb-id|link|title [year]>
+		if len(args) < 2 {
+			commandList.showError(channelID, "`info <movie|show> <tmdb-id|tvdb-id|imdb-id|link|title [year]>`")
+			return
+		}
+
+		backend, ok := mediaBackendFor(commandList, services, channelID, resolveMediaType(args[0]))
+
+		if !ok {
+			return
+		}
+
+		id, err := backend.Lookup(strings.TrimSpace(strings.Join(args[1:], " ")))
+
+		if err != nil {
+			commandList.showError(channelID, err.Error())
+			return
+		}
+
+		info, err := backend.Info(id)
+
+		if err != nil {
+			channelLogger(channelID).Error("fetch info failed", "backend", backend.Name(), "error", err)
+			commandList.showError(channelID, err.Error())
+			return
+		}
+
+		commandList.send(channelID, info.String())
+	}
+}
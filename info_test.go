@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestInfo(t *testing.T) {
+	const (
+		sicario      = "An idealistic FBI agent is enlisted by a government task force to aid in the escalating war against drugs at the border area between the U.S. and Mexico."
+		soldado      = "Agent Matt Graver teams up with operative Alejandro Gillick to prevent Mexican drug cartels from smuggling terrorists across the United States border."
+		breakingBad  = "A high school chemistry teacher diagnosed with terminal lung cancer turns to manufacturing and selling methamphetamine in order to secure his family's future."
+		theBear      = "A young chef from the fine dining world returns to Chicago to run his family's sandwich shop."
+		seasonHeader = "```\nseason  files  episodes\n"
+	)
+
+	runCommandCases(t, "info", []commandCase{
+		{
+			name: "movie in library",
+			args: []string{"movie", "273481"},
+			want: []string{"**Sicario (2015)** `273481`\n" +
+				sicario + "\n\n" +
+				"genres: Action, Crime, Thriller\n" +
+				"runtime: 121 minutes\n" +
+				"rating: 7.4 (7321 votes)\n" +
+				"studio: Lionsgate\n" +
+				"status: released\n" +
+				"library: downloaded\n" +
+				"certification: R\n" +
+				"path: `/movies/Sicario (2015)`\n" +
+				"file: `Sicario (2015) Bluray-1080p.mkv` 8.0 GB, Bluray-1080p\n"},
+			request: "radarr GET /api/v3/movie/1",
+		},
+		{
+			name: "movie not added",
+			args: []string{"movie", "https://www.imdb.com/title/tt5052474/"},
+			setup: func(h *harness) {
+				h.radarr.respond("GET /api/v3/movie/lookup?term=imdb%3Att5052474", 200,
+					`[{"title":"Sicario: Day of the Soldado","year":2018,"tmdbId":400535}]`)
+			},
+			want: []string{"**Sicario: Day of the Soldado (2018)** `400535`\n" +
+				soldado + "\n\n" +
+				"genres: Action, Crime, Thriller\n" +
+				"runtime: 122 minutes\n" +
+				"rating: 6.7 (3702 votes)\n" +
+				"studio: Columbia Pictures\n" +
+				"status: released\n" +
+				"library: not added\n"},
+			request: "radarr GET /api/v3/movie/lookup",
+			query:   map[string]string{"term": "imdb:tt5052474"},
+			check:   nothingSent("radarr", "GET", "/api/v3/movie/1"),
+		},
+		{
+			name: "show in library",
+			args: []string{"show", "breaking", "bad"},
+			want: []string{"**Breaking Bad (2008)** `81189`\n" +
+				breakingBad + "\n\n" +
+				"genres: Crime, Drama, Thriller\n" +
+				"runtime: 47 minutes\n" +
+				"certification: TV-MA\n" +
+				"rating: 9.4 (31714 votes)\n" +
+				"network: AMC\n" +
+				"status: ended\n" +
+				"library: downloaded\n" +
+				"path: `/tv/Breaking Bad`\n" +
+				"size: 100.0 GB\n" +
+				"quality: Bluray-1080p x2, HDTV-720p x1\n" +
+				seasonHeader +
+				"     1      7         7\n" +
+				"     2     13        13\n" +
+				"     3     13        13\n" +
+				"     4     13        13\n" +
+				"     5     16        16\n" +
+				"```"},
+			request: "sonarr GET /api/v3/episodefile",
+			query:   map[string]string{"seriesId": "1"},
+		},
+		{
+			name: "show not added",
+			args: []string{"show", "403245"},
+			want: []string{"**The Bear (2022)** `403245`\n" +
+				theBear + "\n\n" +
+				"genres: Comedy, Drama\n" +
+				"runtime: 30 minutes\n" +
+				"certification: TV-MA\n" +
+				"rating: 8.6 (1204 votes)\n" +
+				"network: FX\n" +
+				"status: continuing\n" +
+				"library: not added\n"},
+		},
+		{
+			name: "library failing",
+			args: []string{"movie", "273481"},
+			setup: func(h *harness) {
+				h.radarr.respond("GET /api/v3/movie", 500, "")
+			},
+			want: []string{"**Sicario (2015)** `273481`\n" +
+				sicario + "\n\n" +
+				"genres: Action, Crime, Thriller\n" +
+				"runtime: 121 minutes\n" +
+				"rating: 7.4 (7321 votes)\n" +
+				"studio: Lionsgate\n" +
+				"status: released\n" +
+				"library: unknown, fetch movies from radarr failed: 500 Internal Server Error\n"},
+		},
+		{
+			name: "show with too many seasons for one message",
+			args: []string{"show", "81189"},
+			setup: func(h *harness) {
+				var seasons []string
+
+				for i := 1; i <= 150; i++ {
+					seasons = append(seasons, fmt.Sprintf(`{"seasonNumber":%d,"statistics":{"episodeFileCount":20,"episodeCount":20}}`, i))
+				}
+
+				h.sonarr.respond("GET /api/v3/series", 200,
+					`[{"id":1,"title":"Breaking Bad","tvdbId":81189,"path":"/tv/Breaking Bad","seasons":[`+strings.Join(seasons, ",")+`]}]`)
+			},
+			check: func(t *testing.T, h *harness) {
+				replies := h.chat.messages(testChannel)
+
+				if len(replies) != 1 {
+					t.Fatalf("replied %q, want one message", replies)
+				}
+
+				reply := replies[0]
+
+				if len(reply) > 2000 {
+					t.Errorf("reply is %d characters, over discord's limit", len(reply))
+				}
+
+				if !strings.Contains(reply, "\n    60     20        20\n... 90 more seasons\n```") {
+					t.Errorf("reply doesn't say how many seasons were left off:\n%s", reply)
+				}
+			},
+		},
+		{
+			name: "lookup failing",
+			args: []string{"movie", "273481"},
+			setup: func(h *harness) {
+				h.radarr.respond("GET /api/v3/movie/lookup/tmdb?tmdbId=273481", 500, "")
+			},
+			want: []string{"failed fetching movie: 500 Internal Server Error"},
+		},
+		{
+			name: "missing id",
+			args: []string{"show"},
+			want: []string{"`info <movie|show> <tmdb-id|tvdb-id|imdb-id|link|title [year]>`"},
+		},
+	})
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// invocation is what a single command did while it ran
+type invocation struct {
+	// command is empty until a command actually runs, e.g. when showing help
+	command string
+	failed  bool
+	// reply is the last message the command sent
+	reply string
+	// replyIDs are the ids of every message the command sent
+	replyIDs []string
+}
+
+// outcome summarizes how the command went for metrics and logs
+func (current invocation) outcome() string {
+	if current.failed {
+		return outcomeError
+	}
+
+	return outcomeOK
+}
+
+// invocations tracks the commands that are running, keyed by the message
+// that triggered each one, so anything a command sends can be attributed to
+// it while other commands in the same channel run alongside
+type invocations struct {
+	mu      sync.Mutex
+	running map[string]*invocation
+}
+
+func newInvocations() *invocations {
+	return &invocations{
+		running: map[string]*invocation{},
+	}
+}
+
+// begin marks a command as running for a message
+func (i *invocations) begin(messageID string) {
+	i.mu.Lock()
+	i.running[messageID] = &invocation{}
+	i.mu.Unlock()
+}
+
+// end stops tracking a message and returns what its command did
+func (i *invocations) end(messageID string) invocation {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	current := i.running[messageID]
+	delete(i.running, messageID)
+
+	return *current
+}
+
+// ran records which command a message ran and returns what it did so far
+func (i *invocations) ran(messageID, command string) (invocation, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	current, ok := i.running[messageID]
+
+	if !ok {
+		return invocation{}, false
+	}
+
+	current.command = command
+
+	return *current, true
+}
+
+// replied records a message sent by the command answering messageID
+func (i *invocations) replied(messageID string, message *discordgo.Message) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if current, ok := i.running[messageID]; ok {
+		current.reply = message.Content
+		current.replyIDs = append(current.replyIDs, message.ID)
+	}
+}
+
+// fail marks the command answering messageID as failed
+func (i *invocations) fail(messageID string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if current, ok := i.running[messageID]; ok {
+		current.failed = true
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// log.go sets up the structured logger shared by the whole bot
+
+var (
+	logger    = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	logLevel  string
+	logFormat string
+)
+
+// newLogger builds a logger that writes to w
+// level is one of debug, info, warn or error and format is logfmt or json
+func newLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+
+	switch strings.ToLower(level) {
+	case "debug":
+		lvl = slog.LevelDebug
+	case "", "info":
+		lvl = slog.LevelInfo
+	case "warn", "warning":
+		lvl = slog.LevelWarn
+	case "error":
+		lvl = slog.LevelError
+	default:
+		return nil, fmt.Errorf("unknown log level %q: should be one of debug|info|warn|error", level)
+	}
+
+	options := &slog.HandlerOptions{Level: lvl}
+
+	switch strings.ToLower(format) {
+	case "", "logfmt", "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q: should be one of logfmt|json", format)
+	}
+}
+
+// isDebug reports whether debug messages are being logged
+// message contents and other chatty output should only be built when true
+func isDebug() bool {
+	return logger.Enabled(context.Background(), slog.LevelDebug)
+}
+
+// channelLogger returns a logger tagged with the channel a command runs in
+func channelLogger(channelID string) *slog.Logger {
+	return logger.With("channel", channelID)
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	radarr "github.com/jrudio/go-radarr-client"
+	sonarr "github.com/jrudio/go-sonarr-client"
+)
+
+// lookup.go turns what users paste into chat into radarr/sonarr lookups
+
+var (
+	imdbIDPattern    = regexp.MustCompile(`^tt\d+$`)
+	titleYearPattern = regexp.MustCompile(`^(.+?)\s*\((\d{4})\)$`)
+	leadingIDPattern = regexp.MustCompile(`^(\d+)`)
+	// trailingYearPattern matches titles followed by a year like `sicario 2015`
+	trailingYearPattern = regexp.MustCompile(`^(.+?)\s+(\d{4})$`)
+)
+
+// mediaQuery is what a user asked us to look up. Only one of the ids,
+// the tvdb slug or the title is set
+type mediaQuery struct {
+	tmdbID   int
+	tvdbID   int
+	imdbID   string
+	tvdbSlug string
+	title    string
+	// year narrows down a title search
+	year int
+}
+
+// parseMediaQuery understands imdb ids (tt0120737), themoviedb, thetvdb and
+// imdb urls and titles with an optional year: sicario (2015)
+// bare numbers are left as titles since plenty of movies are named after years
+func parseMediaQuery(input string) mediaQuery {
+	input = strings.Trim(strings.TrimSpace(input), "\"'`<>")
+
+	if imdbIDPattern.MatchString(input) {
+		return mediaQuery{imdbID: input}
+	}
+
+	if link, err := url.Parse(input); err == nil && link.Host != "" {
+		if query, ok := parseMediaURL(link); ok {
+			return query
+		}
+	}
+
+	if match := titleYearPattern.FindStringSubmatch(input); match != nil {
+		year, _ := strconv.Atoi(match[2])
+
+		return mediaQuery{title: match[1], year: year}
+	}
+
+	return mediaQuery{title: input}
+}
+
+// parseMediaURL pulls an id out of a themoviedb, thetvdb or imdb link
+func parseMediaURL(link *url.URL) (mediaQuery, bool) {
+	host := strings.TrimPrefix(strings.ToLower(link.Host), "www.")
+	segments := strings.Split(strings.Trim(link.Path, "/"), "/")
+
+	switch {
+	case host == "themoviedb.org" && len(segments) >= 2 && segments[0] == "movie":
+		// https://www.themoviedb.org/movie/273481-sicario
+		if match := leadingIDPattern.FindString(segments[1]); match != "" {
+			id, _ := strconv.Atoi(match)
+			return mediaQuery{tmdbID: id}, true
+		}
+	case host == "thetvdb.com":
+		// https://thetvdb.com/?tab=series&id=81189
+		if id, err := strconv.Atoi(link.Query().Get("id")); err == nil {
+			return mediaQuery{tvdbID: id}, true
+		}
+
+		// https://thetvdb.com/series/breaking-bad or https://thetvdb.com/series/81189
+		if len(segments) >= 2 && segments[0] == "series" {
+			if id, err := strconv.Atoi(segments[1]); err == nil {
+				return mediaQuery{tvdbID: id}, true
+			}
+
+			return mediaQuery{tvdbSlug: segments[1]}, true
+		}
+	case host == "imdb.com" || host == "m.imdb.com":
+		// https://www.imdb.com/title/tt0120737/
+		if len(segments) >= 2 && segments[0] == "title" && imdbIDPattern.MatchString(segments[1]) {
+			return mediaQuery{imdbID: segments[1]}, true
+		}
+	}
+
+	return mediaQuery{}, false
+}
+
+// lookupMovies finds movies matching a query via radarr
+func lookupMovies(services clients, query mediaQuery) ([]radarr.Movie, error) {
+	switch {
+	case query.tmdbID != 0:
+		movie, err := services.radarr.GetMovie(query.tmdbID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []radarr.Movie{movie}, nil
+	case query.imdbID != "":
+		return services.radarr.Search("imdb:" + query.imdbID)
+	case query.tvdbID != 0 || query.tvdbSlug != "":
+		return nil, errors.New("that's a thetvdb link, try `show` instead of `movie`")
+	}
+
+	movies, err := services.radarr.Search(query.title)
+
+	if err != nil || query.year == 0 {
+		return movies, err
+	}
+
+	var matches []radarr.Movie
+
+	for _, movie := range movies {
+		if movie.Year == query.year {
+			matches = append(matches, movie)
+		}
+	}
+
+	return matches, nil
+}
+
+// lookupShows finds shows matching a query via sonarr
+func lookupShows(services clients, query mediaQuery) ([]sonarr.SearchResults, error) {
+	switch {
+	case query.tvdbID != 0:
+		return services.sonarr.Search(fmt.Sprintf("tvdb:%d", query.tvdbID))
+	case query.imdbID != "":
+		return services.sonarr.Search("imdb:" + query.imdbID)
+	case query.tmdbID != 0:
+		return nil, errors.New("that's a themoviedb link, try `movie` instead of `show`")
+	case query.tvdbSlug != "":
+		shows, err := services.sonarr.Search(strings.Replace(query.tvdbSlug, "-", " ", -1))
+
+		if err != nil {
+			return shows, err
+		}
+
+		// sonarr's title slugs usually match thetvdb's
+		for _, show := range shows {
+			if show.TitleSlug == query.tvdbSlug {
+				return []sonarr.SearchResults{show}, nil
+			}
+		}
+
+		return shows, nil
+	}
+
+	shows, err := services.sonarr.Search(query.title)
+
+	if err != nil || query.year == 0 {
+		return shows, err
+	}
+
+	var matches []sonarr.SearchResults
+
+	for _, show := range shows {
+		if show.Year == query.year {
+			matches = append(matches, show)
+		}
+	}
+
+	return matches, nil
+}
+
+// candidate is a movie or show a lookup turned up
+type candidate struct {
+	title string
+	year  int
+	// id is the tmdb id of a movie or tvdb id of a show
+	id int
+}
+
+// normalizeTitle lowercases a title and drops punctuation and spacing
+// so `Sicario: Day of the Soldado` matches `sicario day of the soldado`
+func normalizeTitle(title string) string {
+	var normalized []rune
+
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			normalized = append(normalized, r)
+		}
+	}
+
+	return string(normalized)
+}
+
+// pickCandidate chooses the one candidate a query refers to
+// id and link lookups only need a single result, titles need a single
+// result with exactly that title (and year when one was given)
+func pickCandidate(candidates []candidate, query mediaQuery) (candidate, bool) {
+	if query.title == "" {
+		if len(candidates) == 1 {
+			return candidates[0], true
+		}
+
+		return candidate{}, false
+	}
+
+	var exact []candidate
+
+	for _, c := range candidates {
+		if normalizeTitle(c.title) != normalizeTitle(query.title) {
+			continue
+		}
+
+		if query.year != 0 && c.year != query.year {
+			continue
+		}
+
+		exact = append(exact, c)
+	}
+
+	if len(exact) == 1 {
+		return exact[0], true
+	}
+
+	return candidate{}, false
+}
+
+// resolveCandidate returns the id of the one movie or show input refers to.
+// input is an id, anything parseMediaQuery understands or a title followed by
+// a year: `sicario 2015`. lookup searches radarr or sonarr for a query
+func resolveCandidate(input, kind string, lookup func(mediaQuery) ([]candidate, error)) (int, error) {
+	if id, err := strconv.Atoi(input); err == nil {
+		return id, nil
+	}
+
+	query := parseMediaQuery(input)
+
+	var candidates []candidate
+
+	// `sicario 2015` is most likely a title and year
+	if match := trailingYearPattern.FindStringSubmatch(query.title); match != nil && query.year == 0 {
+		year, _ := strconv.Atoi(match[2])
+		withYear := mediaQuery{title: match[1], year: year}
+
+		results, err := lookup(withYear)
+
+		if err != nil {
+			return 0, err
+		}
+
+		if picked, ok := pickCandidate(results, withYear); ok {
+			return picked.id, nil
+		}
+
+		candidates = results
+	}
+
+	// but the year could be part of the title like `blade runner 2049`
+	results, err := lookup(query)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if picked, ok := pickCandidate(results, query); ok {
+		return picked.id, nil
+	}
+
+	if len(candidates) == 0 {
+		candidates = results
+	}
+
+	if len(candidates) == 0 {
+		return 0, fmt.Errorf("could not find a %s matching `%s`", kind, input)
+	}
+
+	return 0, errors.New(disambiguate(input, kind, candidates))
+}
+
+// disambiguate lists the candidates a user can choose from
+func disambiguate(input, kind string, candidates []candidate) string {
+	const maxListed = 5
+
+	output := fmt.Sprintf("`%s` could be more than one %s, add it by id instead:\n", input, kind)
+
+	for i, c := range candidates {
+		if i == maxListed {
+			output += fmt.Sprintf("...and %d more, use `search` to see them all\n", len(candidates)-maxListed)
+			break
+		}
+
+		output += fmt.Sprintf("- %s (%d) `%d`\n", c.title, c.year, c.id)
+	}
+
+	return output
+}
+
+// resolveMovieID returns the tmdb id of the one movie input refers to
+func resolveMovieID(services clients, input string) (int, error) {
+	return resolveCandidate(input, "movie", func(query mediaQuery) ([]candidate, error) {
+		movies, err := lookupMovies(services, query)
+
+		candidates := make([]candidate, len(movies))
+
+		for i, movie := range movies {
+			candidates[i] = candidate{title: movie.Title, year: movie.Year, id: movie.TmdbID}
+		}
+
+		return candidates, err
+	})
+}
+
+// resolveShowID returns the tvdb id of the one show input refers to
+func resolveShowID(services clients, input string) (int, error) {
+	return resolveCandidate(input, "show", func(query mediaQuery) ([]candidate, error) {
+		shows, err := lookupShows(services, query)
+
+		candidates := make([]candidate, len(shows))
+
+		for i, show := range shows {
+			candidates[i] = candidate{title: show.Title, year: show.Year, id: show.TvdbID}
+		}
+
+		return candidates, err
+	})
+}
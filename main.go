@@ -2,14 +2,14 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/jrudio/go-radarr-client"
-	sonarr "github.com/jrudio/go-sonarr-client"
 )
 
 const (
@@ -25,16 +25,20 @@ var (
 	defaultSonarrQualityID int
 	defaultRadarrPath      string
 	defaultRadarrQualityID int
-	version                string
-	versionFlag            *bool
+	// dataDir is where shart keeps state like the audit log
+	dataDir     string
+	version     string
+	versionFlag *bool
 )
 
 type commands interface {
-	execute(channelID, cmd string, args ...string)
+	execute(channelID, cmd string, args ...string) invocation
+	track(messageID string, fn func(commandList commands)) invocation
 	isValid(cmd string) bool
+	invalidCommand(cmd string) string
 	showHelp(channelID string)
 	showError(channelID string, msg string)
-	addCommand(cmd string, fn func(channelID string, args ...string))
+	addCommand(cmd string, build commandBuilder)
 }
 
 type shartCredentials struct {
@@ -59,13 +63,19 @@ type serviceCredentials struct {
 
 type clients struct {
 	// TODO: maybe add discord here as well?
-	radarr radarr.Client
-	sonarr *sonarr.Sonarr
+	// radarr and sonarr speak whichever api version the servers answered at startup
+	radarr movieBackend
+	sonarr showBackend
+	// radarrAPI and sonarrAPI reach endpoints the backends above don't support
+	radarrAPI arrAPI
+	sonarrAPI arrAPI
+	// media holds the backend for each media type, see withMediaBackends
+	media map[string]MediaBackend
 }
 
 func checkErrAndExit(err error) {
 	if err != nil {
-		fmt.Println(err)
+		logger.Error(err.Error())
 		os.Exit(1)
 	}
 }
@@ -74,19 +84,53 @@ func main() {
 
 	credentials, err := getCredentials()
 
-	if err != nil {
-		// most likely errTokenRequired error because user did not pass info via flags
-		// try secrets.toml
+	if isVerbose {
+		logLevel = "debug"
+	}
+
+	if configured, logErr := newLogger(os.Stderr, logLevel, logFormat); logErr != nil {
+		fmt.Println(logErr)
+		os.Exit(1)
+	} else {
+		logger = configured
+	}
+
+	if err != nil && err.Error() == errTokenRequired {
+		// user did not pass a token via flags so try secrets.toml
 		credentials, err = getCredentialsTOML("./secrets.toml")
 
 		if err != nil {
-			fmt.Printf("need credentials: %v\n", err)
+			logger.Error("need credentials", "error", err)
 			os.Exit(1)
 		}
 	}
 
+	checkErrAndExit(err)
+
+	err = commandLimiter.configure(rateLimitUser, rateLimitChannel, rateLimitCommands)
+
+	checkErrAndExit(err)
+
+	err = validateSeriesFolderFormat(seriesFolderFormat)
+
+	checkErrAndExit(err)
+
+	err = os.MkdirAll(dataDir, 0700)
+
+	checkErrAndExit(err)
+
+	auditTrail = newAuditLog(dataDir)
+
+	err = loadShowDefaults(dataDir)
+
+	checkErrAndExit(err)
+
+	messageExpiry, err = newExpiryScheduler(dataDir, expireAfter)
+
+	checkErrAndExit(err)
+
 	if keyword == "" {
-		fmt.Println("a keyword (or trigger) is required for shart to work")
+		logger.Error("a keyword (or trigger) is required for shart to work")
 		os.Exit(1)
 	}
 
@@ -94,18 +138,35 @@ func main() {
 
 	checkErrAndExit(err)
 
+	instrumentBackends(credentials)
+
+	checks := checkBackends(services)
+
+	if strictStartup {
+		for _, check := range checks {
+			if check.err != nil {
+				logger.Error("refusing to start with -strict", "backend", check.name)
+				os.Exit(1)
+			}
+		}
+	}
+
+	backends := newBackendState(services, checks)
+
 	discord, err := discordgo.New("Bot " + credentials.shart.token)
 
 	checkErrAndExit(err)
 
+	watchGateway(discord)
+
 	// get keyword length
 	keywordLen = len(keyword)
 
-	commandList := newDiscord(discord)
+	commandList := newDiscord(discordSession{discord})
 
-	commandList = addCommands(commandList, services)
+	commandList = addCommands(commandList, backends)
 
-	discord.AddHandler(onMsgCreate(commandList))
+	discord.AddHandler(onMsgCreate(commandList, backends))
 
 	err = discord.Open()
 
@@ -113,7 +174,46 @@ func main() {
 
 	defer discord.Close()
 
-	fmt.Println("bot is listening...")
+	stopExpiry := make(chan struct{})
+
+	go messageExpiry.run(commandList.discord, 30*time.Second, stopExpiry)
+
+	defer close(stopExpiry)
+
+	if adminChannel != "" {
+		stopHealthWatcher := make(chan struct{})
+
+		go newHealthWatcher().run(commandList, backends, healthCheckInterval, stopHealthWatcher)
+
+		defer close(stopHealthWatcher)
+	}
+
+	if adminChannel != "" && lowDiskThreshold != "" {
+		watcher, err := newDiskWatcher(lowDiskThreshold)
+
+		checkErrAndExit(err)
+
+		stopDiskWatcher := make(chan struct{})
+
+		go watcher.run(commandList, backends, diskCheckInterval, stopDiskWatcher)
+
+		defer close(stopDiskWatcher)
+	}
+
+	stopBackendWatcher := make(chan struct{})
+
+	// backends that failed their startup check are checked again as often as their health
+	go backends.watch(commandList, healthCheckInterval, stopBackendWatcher)
+
+	defer close(stopBackendWatcher)
+
+	server := newHTTPServer(httpAddr, backends)
+
+	serveHTTP(server)
+
+	defer server.Close()
+
+	logger.Info("bot is listening...", "version", version)
 
 	ctrlC := make(chan os.Signal, 1)
 
@@ -122,16 +222,12 @@ func main() {
 	<-ctrlC
 }
 
-func onMsgCreate(commandList commands) func(s *discordgo.Session, m *discordgo.MessageCreate) {
+func onMsgCreate(commandList commands, backends *backendState) func(s *discordgo.Session, m *discordgo.MessageCreate) {
 	return func(s *discordgo.Session, m *discordgo.MessageCreate) {
 		if m.Author.ID == s.State.User.ID {
 			return
 		}
 
-		if isVerbose {
-			fmt.Println(m.Content)
-		}
-
 		messageLen := len(m.Content)
 
 		if messageLen < keywordLen {
@@ -143,54 +239,160 @@ func onMsgCreate(commandList commands) func(s *discordgo.Session, m *discordgo.M
 			return
 		}
 
-		// user triggered keyword so lets see what subcommand was requested
-		if messageLen > keywordLen {
-			// user has a subcommand
+		requestLog := logger.With(
+			"guild", guildID(s, m.ChannelID),
+			"channel", m.ChannelID,
+			"user", m.Author.ID,
+		)
 
-			args := strings.Split(m.Content, " ")
-			argCount := len(args)
+		// message bodies may hold private conversation so keep them out of normal logs
+		if isDebug() {
+			requestLog.Debug("message received", "content", m.Content)
+		}
 
-			// remove the keyword
-			args = args[1:argCount]
+		result := commandList.track(m.ID, func(commandList commands) {
+			dispatch(s, m, commandList, backends, requestLog)
+		})
 
-			argCount--
+		if err := messageExpiry.schedule(m.ChannelID, m.ID, result); err != nil {
+			requestLog.Error("schedule message expiry failed", "error", err)
+		}
+	}
+}
 
-			subcommand := args[0]
+// dispatch runs the command a message asked for
+func dispatch(s *discordgo.Session, m *discordgo.MessageCreate, commandList commands, backends *backendState, requestLog *slog.Logger) {
+	messageLen := len(m.Content)
 
-			if !commandList.isValid(subcommand) {
-				// let user know that command wasn't valid
-				commandList.showError(m.ChannelID, "invalid command")
-				return
-			}
+	// user triggered keyword so lets see what subcommand was requested
+	if messageLen > keywordLen {
+		// user has a subcommand
+
+		args := strings.Split(m.Content, " ")
+		argCount := len(args)
+
+		// remove the keyword
+		args = args[1:argCount]
+
+		argCount--
+
+		subcommand := args[0]
+
+		if !commandList.isValid(subcommand) {
+			requestLog.Info("invalid command", "command", subcommand)
 
-			// remove the subcommand
-			args = args[1:argCount]
+			// let user know that command wasn't valid
+			commandList.showError(m.ChannelID, commandList.invalidCommand(subcommand))
+			return
+		}
+
+		// remove the subcommand
+		args = args[1:argCount]
 
-			commandList.execute(m.ChannelID, subcommand, args...)
-		} else {
-			// it's only the keyword so return a list of subcommands
-			commandList.showHelp(m.ChannelID)
+		admin := isAdmin(s, m.Author.ID, m.ChannelID)
+
+		if adminCommands[subcommand] && !admin {
+			requestLog.Info("admin command refused", "command", subcommand)
+			commandList.showError(m.ChannelID, fmt.Sprintf("`%s` is only available to admins", subcommand))
+			return
 		}
 
-		// TODO: maybe keep track of user and their subsequent commands
-		// so multiple users don't mess each other up
+		if reason := backends.backendUnavailable(subcommand, args); reason != "" {
+			requestLog.Info("backend unavailable", "command", subcommand, "reason", reason)
+			commandList.showError(m.ChannelID, reason)
+			return
+		}
+
+		if !admin {
+			if wait, scope := commandLimiter.allow(m.Author.ID, m.ChannelID, subcommand, time.Now()); wait > 0 {
+				requestLog.Info("command rate limited",
+					"command", subcommand,
+					"scope", scope,
+					"wait", wait,
+				)
+
+				commandRateLimits.inc(scope)
+				commandsTotal.inc(subcommand, outcomeRateLimited)
+				commandList.showError(m.ChannelID, rateLimitedMessage(subcommand, wait))
+				return
+			}
+		}
 
-		// fmt.Println(m.Content)
+		start := time.Now()
+
+		result := commandList.execute(m.ChannelID, subcommand, args...)
+
+		requestLog.Info("command executed",
+			"command", subcommand,
+			"outcome", result.outcome(),
+			"latency", time.Since(start),
+		)
+
+		if isAudited(subcommand, args) {
+			err := auditTrail.record(auditEntry{
+				Time:      start,
+				GuildID:   guildID(s, m.ChannelID),
+				ChannelID: m.ChannelID,
+				UserID:    m.Author.ID,
+				Username:  m.Author.Username,
+				Command:   subcommand,
+				Args:      args,
+				Outcome:   result.outcome(),
+				Reply:     result.reply,
+			})
+
+			if err != nil {
+				requestLog.Error("audit record failed", "command", subcommand, "error", err)
+			}
+		}
+	} else {
+		// it's only the keyword so return a list of subcommands
+		commandList.showHelp(m.ChannelID)
 	}
+
+	// TODO: maybe keep track of user and their subsequent commands
+	// so multiple users don't mess each other up
 }
 
-func addCommands(commandList d, services clients) d {
-	commandList.addCommand("search", search(commandList, services))
+func addCommands(commandList d, backends *backendState) d {
+	commandList.backends = backends
+
+	commandList.addCommand("search", search)
 
 	// clear deletes messages in a channel -- user can delete x messages
-	commandList.addCommand("clear", clearMessages(commandList, services))
-	commandList.addCommand("add", addMedia(commandList, services))
-	commandList.addCommand("quality", showQualityProfiles(commandList, services))
-	commandList.addCommand("folders", showRootFolders(commandList, services))
-	commandList.addCommand("set-quality", setQualityProfile(commandList, services))
-	commandList.addCommand("set-folder", setRootFolder(commandList, services))
-	commandList.addCommand("discover", discoverMedia(commandList, services))
-	commandList.addCommand("library", showLibrary(commandList, services))
+	commandList.addCommand("clear", clearMessages)
+	commandList.addCommand("add", addMedia)
+	commandList.addCommand("quality", showQualityProfiles)
+	commandList.addCommand("folders", showRootFolders)
+	commandList.addCommand("disk", showDiskSpace)
+	commandList.addCommand("set-quality", setQualityProfile)
+	commandList.addCommand("set-folder", setRootFolder)
+	commandList.addCommand("languages", showLanguageProfiles)
+	commandList.addCommand("defaults", setShowDefaults)
+	commandList.addCommand("discover", discoverMedia)
+	commandList.addCommand("library", showLibrary)
+	commandList.addCommand("status", showMediaStatus)
+	commandList.addCommand("info", showInfo)
+	commandList.addCommand("episode", showEpisode)
+	commandList.addCommand("season", searchSeason)
+	commandList.addCommand("wanted", showWanted)
+	commandList.addCommand("releases", showReleases)
+	commandList.addCommand("grab", grabRelease)
+	commandList.addCommand("audit", func(commandList d, services clients) func(channelID string, args ...string) {
+		return showAudit(commandList, auditTrail)
+	})
 
 	return commandList
 }
+
+// guildID looks up the guild a channel belongs to
+// direct messages have no guild so an empty string is returned
+func guildID(s *discordgo.Session, channelID string) string {
+	channel, err := s.State.Channel(channelID)
+
+	if err != nil {
+		return ""
+	}
+
+	return channel.GuildID
+}
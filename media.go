@@ -0,0 +1,548 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	radarr "github.com/jrudio/go-radarr-client"
+	sonarr "github.com/jrudio/go-sonarr-client"
+)
+
+// media.go puts radarr and sonarr behind one interface so each command is
+// written once no matter which kind of media it's asked about
+
+// MediaBackend is a service that manages one type of media, like radarr for movies
+type MediaBackend interface {
+	// Name is what the backend is called in replies and logs, e.g. radarr
+	Name() string
+	// API reaches the backend's endpoints directly
+	API() arrAPI
+	// UseV3 returns services with the clients the backend is built from
+	// switched over to the v3 api
+	UseV3(services clients) clients
+	// Settings are the root folder and quality profile media is added with
+	Settings() mediaSettings
+	// Search finds media matching a query
+	Search(query mediaQuery) ([]media, error)
+	// Lookup returns the id of the one piece of media input refers to: an id, link or title
+	Lookup(input string) (int, error)
+	// CheckAddOptions returns a usageError when options has flags the
+	// backend doesn't support, before anything is looked up
+	CheckAddOptions(options addOptions) error
+	// Add adds media by id and returns what was added. Adding media that's
+	// already in the library returns it along with errAlreadyAdded
+	Add(id int, request addRequest) (media, error)
+	Profiles() ([]namedID, error)
+	RootFolders() ([]rootFolder, error)
+	// Library returns everything in the library sorted by title
+	Library() ([]media, error)
+	// Discover returns media the backend recommends
+	Discover() ([]media, error)
+	// Info describes media by id in detail, along with its copy in the library
+	Info(id int) (mediaInfo, error)
+	// ReleaseSearch works out which media in the library args ask for the
+	// releases of. It returns a name for it and the parameters to search
+	// with. Mistakes in how args are written are usageErrors
+	ReleaseSearch(args []string) (string, url.Values, error)
+	// Wanted returns a page of the missing or cutoff list
+	Wanted(list string, page int) (wantedPage, error)
+	// SearchWanted starts the backend's search for everything on the
+	// missing or cutoff list and returns the command it started
+	SearchWanted(list string) (string, commandStatus, error)
+}
+
+// mediaBackendConstructors build the backend for each media type. New
+// backends, like lidarr for music, are added here
+var mediaBackendConstructors = map[string]func(services clients) MediaBackend{
+	"movie": newRadarrBackend,
+	"show":  newSonarrBackend,
+}
+
+// withMediaBackends builds the backend for every media type. It has to run
+// again whenever the clients change so the backends see the new ones
+func withMediaBackends(services clients) clients {
+	services.media = map[string]MediaBackend{}
+
+	for mediaType, newBackend := range mediaBackendConstructors {
+		services.media[mediaType] = newBackend(services)
+	}
+
+	return services
+}
+
+// mediaBackend returns the backend for a media type
+func (services clients) mediaBackend(mediaType string) (MediaBackend, bool) {
+	backend, ok := services.media[mediaType]
+
+	return backend, ok
+}
+
+// mediaBackends returns every backend in the order of mediaTypes
+func (services clients) mediaBackends() []MediaBackend {
+	var backends []MediaBackend
+
+	for _, mediaType := range mediaTypes {
+		if backend, ok := services.media[mediaType]; ok {
+			backends = append(backends, backend)
+		}
+	}
+
+	return backends
+}
+
+// errAlreadyAdded is returned when adding media that's already in the library
+var errAlreadyAdded = errors.New("is already added")
+
+// usageError is a mistake in how a command was written, replies follow it with the usage
+type usageError struct {
+	error
+}
+
+// media is a movie, show or anything else a backend manages
+type media struct {
+	// id is the id the backend's lookup knows it by: tmdb for movies, tvdb for shows
+	id       int
+	title    string
+	year     int
+	overview string
+	path     string
+	// status describes media in the library, e.g. `downloaded`
+	status     string
+	monitored  bool
+	downloaded bool
+	// releaseStatus is where the media is at, e.g. `announced` or `continuing`
+	releaseStatus string
+	// libraryID is the id the backend knows media in its library by
+	libraryID int
+	size      int64
+	// seasons are a show's seasons, other media has none
+	seasons []season
+}
+
+// season counts a show's episodes in one season
+type season struct {
+	number int
+	// files is how many of its episodes were downloaded
+	files    int
+	episodes int
+}
+
+// libraryItem returns the media in backend's library input refers to
+func libraryItem(backend MediaBackend, mediaType, input string) (media, error) {
+	id, err := backend.Lookup(input)
+
+	if err != nil {
+		return media{}, err
+	}
+
+	items, err := backend.Library()
+
+	if err != nil {
+		return media{}, err
+	}
+
+	item, ok := libraryByID(items)[id]
+
+	if !ok {
+		return item, fmt.Errorf("`%s` is not in your library, `add %s %d` first", input, mediaType, id)
+	}
+
+	return item, nil
+}
+
+// libraryByID keys media by id
+func libraryByID(items []media) map[int]media {
+	library := make(map[int]media, len(items))
+
+	for _, item := range items {
+		library[item.id] = item
+	}
+
+	return library
+}
+
+// mediaSettings point at the root folder and quality profile a backend adds media with
+type mediaSettings struct {
+	rootFolderPath   *string
+	qualityProfileID *int
+}
+
+// addRequest is how media should be added
+type addRequest struct {
+	// channelID is the channel asking, for its show defaults
+	channelID        string
+	rootFolderPath   string
+	qualityProfileID int
+	options          addOptions
+}
+
+// resolveProfile finds a quality profile given its name or id
+func resolveProfile(backend MediaBackend, nameOrID string) (namedID, error) {
+	profiles, err := backend.Profiles()
+
+	if err != nil {
+		return namedID{}, err
+	}
+
+	return pickNamed(profiles, nameOrID, "quality profile")
+}
+
+// resolveRootFolder finds a root folder given its path or id
+func resolveRootFolder(backend MediaBackend, pathOrID string) (rootFolder, error) {
+	folders, err := backend.RootFolders()
+
+	if err != nil {
+		return rootFolder{}, err
+	}
+
+	return pickRootFolder(folders, pathOrID)
+}
+
+// radarrBackend manages movies
+type radarrBackend struct {
+	services clients
+}
+
+func newRadarrBackend(services clients) MediaBackend {
+	return radarrBackend{services: services}
+}
+
+func (backend radarrBackend) Name() string {
+	return backend.services.radarrAPI.name
+}
+
+func (backend radarrBackend) API() arrAPI {
+	return backend.services.radarrAPI
+}
+
+func (backend radarrBackend) Settings() mediaSettings {
+	return mediaSettings{rootFolderPath: &defaultRadarrPath, qualityProfileID: &defaultRadarrQualityID}
+}
+
+// movieMedia describes a movie, its status is only filled in for movies in the library
+func movieMedia(movie radarr.Movie) media {
+	item := media{
+		id:            movie.TmdbID,
+		title:         movie.Title,
+		year:          movie.Year,
+		overview:      movie.Overview,
+		path:          movie.Path,
+		monitored:     movie.Monitored,
+		downloaded:    movie.HasFile || movie.Downloaded,
+		releaseStatus: movie.Status,
+		libraryID:     movie.ID,
+		size:          int64(movie.SizeOnDisk),
+	}
+
+	if movie.ID != 0 {
+		item.status = movieStatus(movie)
+	}
+
+	return item
+}
+
+func movieMedias(movies []radarr.Movie) []media {
+	items := make([]media, len(movies))
+
+	for i, movie := range movies {
+		items[i] = movieMedia(movie)
+	}
+
+	return items
+}
+
+func (backend radarrBackend) Search(query mediaQuery) ([]media, error) {
+	movies, err := lookupMovies(backend.services, query)
+
+	return movieMedias(movies), err
+}
+
+func (backend radarrBackend) Lookup(input string) (int, error) {
+	return resolveMovieID(backend.services, input)
+}
+
+func (backend radarrBackend) CheckAddOptions(options addOptions) error {
+	if flag := options.showOnly(); flag != "" {
+		return usageError{fmt.Errorf("`%s` only applies to shows", flag)}
+	}
+
+	return nil
+}
+
+func (backend radarrBackend) Add(tmdbID int, request addRequest) (media, error) {
+	options := request.options
+
+	movie, err := backend.services.radarr.GetMovie(tmdbID)
+
+	if err != nil {
+		return media{}, fmt.Errorf("failed fetching movie: %v", err)
+	}
+
+	// tweak fields to make a proper request
+	movie.AddOptions.SearchForMovie = !options.noSearch
+	movie.Monitored = !options.unmonitored
+	movie.QualityProfileID = request.qualityProfileID
+	movie.RootFolderPath = request.rootFolderPath
+
+	if options.availability != "" {
+		if movie.MinimumAvailability, err = resolveAvailability(options.availability); err != nil {
+			return media{}, err
+		}
+	}
+
+	if len(options.tags) > 0 {
+		tagIDs, err := backend.services.radarrAPI.tagIDs(options.tags)
+
+		if err != nil {
+			return media{}, fmt.Errorf("failed resolving tags: %v", err)
+		}
+
+		// radarr accepts tag ids as strings
+		movie.Tags = nil
+
+		for _, id := range tagIDs {
+			movie.Tags = append(movie.Tags, strconv.Itoa(id))
+		}
+	}
+
+	added := movieMedia(movie)
+
+	// radarr picks the folder inside the root folder so there's no path to report
+	added.path = ""
+
+	if errs := backend.services.radarr.AddMovie(movie); errs != nil {
+		var messages []string
+
+		for _, err := range errs {
+			if err == radarr.ErrorMovieExists {
+				return added, errAlreadyAdded
+			}
+
+			messages = append(messages, err.Error())
+		}
+
+		return added, errors.New(strings.Join(messages, "; "))
+	}
+
+	return added, nil
+}
+
+func (backend radarrBackend) Profiles() ([]namedID, error) {
+	profiles, err := backend.services.radarr.GetProfiles()
+
+	if err != nil {
+		return nil, fmt.Errorf("fetch radarr quality profiles failed: %v", err)
+	}
+
+	items := make([]namedID, len(profiles))
+
+	for i, profile := range profiles {
+		items[i] = namedID{id: profile.ID, name: profile.Name}
+	}
+
+	return items, nil
+}
+
+func (backend radarrBackend) RootFolders() ([]rootFolder, error) {
+	return fetchMovieFolders(backend.services)
+}
+
+func (backend radarrBackend) Library() ([]media, error) {
+	movies, err := backend.services.radarr.GetMovies(radarr.GetMovieOptions{
+		Page:     "1",
+		PageSize: "-1",
+		SortKey:  "sortTitle",
+		SortDir:  "asc",
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("fetch movies from radarr failed: %v", err)
+	}
+
+	return movieMedias(movies), nil
+}
+
+func (backend radarrBackend) Discover() ([]media, error) {
+	movies, err := backend.services.radarr.DiscoverMovies()
+
+	if err != nil {
+		return nil, fmt.Errorf("fetch movies failed: %v", err)
+	}
+
+	return movieMedias(movies), nil
+}
+
+// sonarrBackend manages shows
+type sonarrBackend struct {
+	services clients
+}
+
+func newSonarrBackend(services clients) MediaBackend {
+	return sonarrBackend{services: services}
+}
+
+func (backend sonarrBackend) Name() string {
+	return backend.services.sonarrAPI.name
+}
+
+func (backend sonarrBackend) API() arrAPI {
+	return backend.services.sonarrAPI
+}
+
+func (backend sonarrBackend) Settings() mediaSettings {
+	return mediaSettings{rootFolderPath: &defaultSonarrPath, qualityProfileID: &defaultSonarrQualityID}
+}
+
+// showMedia describes a series in sonarr's library
+func showMedia(show sonarr.Series) media {
+	item := media{
+		id:            show.TvdbID,
+		title:         show.Title,
+		year:          show.Year,
+		overview:      show.Overview,
+		path:          show.Path,
+		status:        showStatus(show),
+		monitored:     show.Monitored,
+		downloaded:    show.EpisodeCount > 0 && show.EpisodeFileCount >= show.EpisodeCount,
+		releaseStatus: show.Status,
+		libraryID:     show.ID,
+		size:          int64(show.SizeOnDisk),
+	}
+
+	for _, s := range show.Seasons {
+		item.seasons = append(item.seasons, season{
+			number:   s.SeasonNumber,
+			files:    s.Statistics.EpisodeFileCount,
+			episodes: s.Statistics.EpisodeCount,
+		})
+	}
+
+	return item
+}
+
+func (backend sonarrBackend) Search(query mediaQuery) ([]media, error) {
+	shows, err := lookupShows(backend.services, query)
+
+	items := make([]media, len(shows))
+
+	for i, show := range shows {
+		items[i] = media{
+			id:            show.TvdbID,
+			title:         show.Title,
+			year:          show.Year,
+			overview:      show.Overview,
+			releaseStatus: show.Status,
+		}
+	}
+
+	return items, err
+}
+
+func (backend sonarrBackend) Lookup(input string) (int, error) {
+	return resolveShowID(backend.services, input)
+}
+
+func (backend sonarrBackend) CheckAddOptions(options addOptions) error {
+	if options.availability != "" {
+		return usageError{errors.New("`--availability` only applies to movies")}
+	}
+
+	return nil
+}
+
+func (backend sonarrBackend) Add(tvdbID int, request addRequest) (media, error) {
+	options := request.options
+
+	defaults, err := applyShowOptions(backend.services, showDefaultsFor(request.channelID), options)
+
+	if err != nil {
+		return media{}, err
+	}
+
+	show, err := backend.services.sonarr.GetSeriesFromTVDB(tvdbID)
+
+	if err != nil {
+		return media{}, fmt.Errorf("failed fetching show: %v", err)
+	}
+
+	folder, err := seriesFolderName(seriesFolderFormat, show.Title, show.Year, show.TvdbID)
+
+	if err != nil {
+		return media{}, err
+	}
+
+	// tweak fields to make a proper request
+	show.AddOptions.SearchForMissingEpisodes = !options.noSearch
+	show.Monitored = !options.unmonitored
+	show.QualityProfileID = request.qualityProfileID
+	show.Path = joinSeriesPath(request.rootFolderPath, folder)
+	show.SeriesType = defaults.seriesType
+	show.SeasonFolder = defaults.seasonFolders
+
+	if len(options.tags) > 0 {
+		if show.Tags, err = backend.services.sonarrAPI.tagIDs(options.tags); err != nil {
+			return media{}, fmt.Errorf("failed resolving tags: %v", err)
+		}
+	}
+
+	added := showMedia(*show)
+
+	if err := addSeries(backend.services, *show, defaults.languageProfileID); err != nil {
+		if strings.Contains(err.Error(), sonarr.ErrorSeriesExists.Error()) {
+			return added, errAlreadyAdded
+		}
+
+		return added, err
+	}
+
+	return added, nil
+}
+
+func (backend sonarrBackend) Profiles() ([]namedID, error) {
+	profiles, err := backend.services.sonarr.GetProfiles()
+
+	if err != nil {
+		return nil, fmt.Errorf("fetch sonarr quality profiles failed: %v", err)
+	}
+
+	items := make([]namedID, len(profiles))
+
+	for i, profile := range profiles {
+		items[i] = namedID{id: profile.ID, name: profile.Name}
+	}
+
+	return items, nil
+}
+
+func (backend sonarrBackend) RootFolders() ([]rootFolder, error) {
+	return fetchShowFolders(backend.services)
+}
+
+func (backend sonarrBackend) Library() ([]media, error) {
+	shows, err := backend.services.sonarr.GetAllSeries()
+
+	if err != nil {
+		return nil, fmt.Errorf("fetch series from sonarr failed: %v", err)
+	}
+
+	sort.SliceStable(shows, func(i, j int) bool {
+		return shows[i].SortTitle < shows[j].SortTitle
+	})
+
+	items := make([]media, len(shows))
+
+	for i, show := range shows {
+		items[i] = showMedia(show)
+	}
+
+	return items, nil
+}
+
+// Discover isn't something sonarr does
+func (backend sonarrBackend) Discover() ([]media, error) {
+	return nil, errors.New("sonarr doesn't recommend shows, try `discover movie`")
+}
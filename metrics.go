@@ -0,0 +1,321 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// metrics.go exposes counters, gauges and histograms in the prometheus text format
+// the handful of metrics shart needs doesn't warrant a client library
+
+// command outcomes recorded by shart_commands_total
+const (
+	outcomeOK          = "ok"
+	outcomeError       = "error"
+	outcomeRateLimited = "rate_limited"
+)
+
+var (
+	commandsTotal = newCounter("shart_commands_total",
+		"Commands executed by name and outcome.", "command", "outcome")
+	backendRequestDuration = newHistogram("shart_backend_request_duration_seconds",
+		"Latency of calls made to radarr and sonarr.",
+		[]float64{.05, .1, .25, .5, 1, 2.5, 5, 10}, "backend", "code")
+	discordSendFailures = newCounter("shart_discord_send_failures_total",
+		"Messages that could not be sent to discord.")
+	discordRateLimits = newCounter("shart_discord_rate_limits_total",
+		"Requests discord rate limited.")
+	commandRateLimits = newCounter("shart_command_rate_limits_total",
+		"Commands refused by shart's rate limiter by the limit that was hit.", "scope")
+	gatewayConnected = newGauge("shart_discord_gateway_connected",
+		"Whether the discord gateway connection is up (1) or down (0).")
+
+	registeredMetrics = []metric{
+		commandsTotal,
+		backendRequestDuration,
+		discordSendFailures,
+		discordRateLimits,
+		commandRateLimits,
+		gatewayConnected,
+	}
+)
+
+type metric interface {
+	write(w io.Writer)
+}
+
+// metricDesc holds what every metric type has in common
+type metricDesc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (desc metricDesc) header(w io.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", desc.name, desc.help, desc.name, kind)
+}
+
+// key joins label values so they can be used as a map key
+func (desc metricDesc) key(labelValues []string) string {
+	if len(labelValues) != len(desc.labels) {
+		panic(fmt.Sprintf("metric %s: expected %d label values, got %d",
+			desc.name, len(desc.labels), len(labelValues)))
+	}
+
+	return strings.Join(labelValues, "\xff")
+}
+
+// format renders label pairs as {a="1",b="2"} plus any extra pairs
+func (desc metricDesc) format(key string, extra ...string) string {
+	var pairs []string
+
+	if len(desc.labels) > 0 {
+		for i, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, desc.labels[i]+"="+strconv.Quote(value))
+		}
+	}
+
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+"="+strconv.Quote(extra[i+1]))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// counter is a monotonically increasing value per label combination
+type counter struct {
+	metricDesc
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newCounter(name, help string, labels ...string) *counter {
+	return &counter{
+		metricDesc: metricDesc{name: name, help: help, labels: labels},
+		values:     map[string]float64{},
+	}
+}
+
+func (c *counter) inc(labelValues ...string) {
+	key := c.key(labelValues)
+
+	c.mu.Lock()
+	c.values[key]++
+	c.mu.Unlock()
+}
+
+func (c *counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.header(w, "counter")
+
+	// unlabelled counters are always reported so alerts can rely on them
+	if len(c.labels) == 0 && len(c.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", c.name)
+	}
+
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %v\n", c.name, c.format(key), c.values[key])
+	}
+}
+
+// gauge is a value that can go up and down
+type gauge struct {
+	metricDesc
+	mu    sync.Mutex
+	value float64
+}
+
+func newGauge(name, help string) *gauge {
+	return &gauge{metricDesc: metricDesc{name: name, help: help}}
+}
+
+func (g *gauge) set(value float64) {
+	g.mu.Lock()
+	g.value = value
+	g.mu.Unlock()
+}
+
+func (g *gauge) get() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.value
+}
+
+func (g *gauge) write(w io.Writer) {
+	g.header(w, "gauge")
+	fmt.Fprintf(w, "%s %v\n", g.name, g.get())
+}
+
+// histogram counts observations into cumulative buckets per label combination
+type histogram struct {
+	metricDesc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(name, help string, buckets []float64, labels ...string) *histogram {
+	return &histogram{
+		metricDesc: metricDesc{name: name, help: help, labels: labels},
+		buckets:    buckets,
+		series:     map[string]*histogramSeries{},
+	}
+}
+
+func (h *histogram) observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	series, ok := h.series[key]
+
+	if !ok {
+		series = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = series
+	}
+
+	for i, upperBound := range h.buckets {
+		if value <= upperBound {
+			series.counts[i]++
+		}
+	}
+
+	series.count++
+	series.sum += value
+}
+
+func (h *histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.header(w, "histogram")
+
+	keys := make([]string, 0, len(h.series))
+
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		series := h.series[key]
+
+		for i, upperBound := range h.buckets {
+			le := strconv.FormatFloat(upperBound, 'g', -1, 64)
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.format(key, "le", le), series.counts[i])
+		}
+
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.format(key, "le", "+Inf"), series.count)
+		fmt.Fprintf(w, "%s_sum%s %v\n", h.name, h.format(key), series.sum)
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.format(key), series.count)
+	}
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// metricsHandler serves every registered metric
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	for _, m := range registeredMetrics {
+		m.write(w)
+	}
+}
+
+// backendTransport times requests made to radarr and sonarr
+// the api clients don't let us swap their http client so this wraps
+// http.DefaultTransport and labels requests by the host they are sent to
+type backendTransport struct {
+	next     http.RoundTripper
+	backends map[string]string
+}
+
+func (t backendTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	backend, ok := t.backends[req.URL.Host]
+
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+
+	start := time.Now()
+
+	resp, err := t.next.RoundTrip(req)
+
+	code := "error"
+
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+
+	backendRequestDuration.observe(time.Since(start).Seconds(), backend, code)
+
+	return resp, err
+}
+
+// instrumentBackends starts timing every request sent to radarr or sonarr
+func instrumentBackends(credentials serviceCredentials) {
+	backends := map[string]string{}
+
+	if radarrURL, err := url.Parse(credentials.radarr.url); err == nil {
+		backends[radarrURL.Host] = "radarr"
+	}
+
+	if sonarrURL, err := url.Parse(credentials.sonarr.url); err == nil {
+		backends[sonarrURL.Host] = "sonarr"
+	}
+
+	http.DefaultTransport = backendTransport{
+		next:     http.DefaultTransport,
+		backends: backends,
+	}
+}
+
+// watchGateway keeps the gateway gauge and rate limit counter up to date
+func watchGateway(session *discordgo.Session) {
+	session.AddHandler(func(s *discordgo.Session, c *discordgo.Connect) {
+		gatewayConnected.set(1)
+	})
+
+	session.AddHandler(func(s *discordgo.Session, c *discordgo.Disconnect) {
+		gatewayConnected.set(0)
+	})
+
+	session.AddHandler(func(s *discordgo.Session, r *discordgo.RateLimit) {
+		discordRateLimits.inc()
+		logger.Warn("discord rate limit hit", "url", r.URL, "retry_after", r.RetryAfter)
+	})
+}
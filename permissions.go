@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// permissions.go decides who counts as a shart admin
+
+// adminCommands may only be run by admins
+// clear is here because it can remove anyone's messages
+var adminCommands = map[string]bool{
+	"audit": true,
+	"clear": true,
+	"grab":  true,
+}
+
+// adminUsers is a comma separated list of discord user ids that are always admins
+var adminUsers string
+
+// adminChannel is the discord channel id alerts meant for admins are posted to
+var adminChannel string
+
+// isAdmin reports whether a user is listed in -admins or may manage the
+// server the channel belongs to
+func isAdmin(s *discordgo.Session, userID, channelID string) bool {
+	for _, adminID := range strings.Split(adminUsers, ",") {
+		if strings.TrimSpace(adminID) == userID {
+			return true
+		}
+	}
+
+	permissions, err := s.State.UserChannelPermissions(userID, channelID)
+
+	if err != nil {
+		return false
+	}
+
+	return permissions&discordgo.PermissionAdministrator != 0 ||
+		permissions&discordgo.PermissionManageServer != 0
+}
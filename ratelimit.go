@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ratelimit.go keeps a single user or channel from hammering radarr and sonarr
+
+var (
+	commandLimiter = newRateLimiter()
+
+	rateLimitUser     string
+	rateLimitChannel  string
+	rateLimitCommands string
+)
+
+// rate allows burst requests every per, refilling continuously
+type rate struct {
+	burst int
+	per   time.Duration
+}
+
+// parseRate parses rates written as `<count>/<duration>` like `5/1m`
+func parseRate(str string) (rate, error) {
+	parts := strings.SplitN(str, "/", 2)
+
+	if len(parts) != 2 {
+		return rate{}, fmt.Errorf("invalid rate %q: should look like 5/1m", str)
+	}
+
+	burst, err := strconv.Atoi(parts[0])
+
+	if err != nil || burst < 1 {
+		return rate{}, fmt.Errorf("invalid rate %q: count should be a positive number", str)
+	}
+
+	per, err := time.ParseDuration(parts[1])
+
+	if err != nil || per <= 0 {
+		return rate{}, fmt.Errorf("invalid rate %q: duration should look like 30s or 1m", str)
+	}
+
+	return rate{burst: burst, per: per}, nil
+}
+
+// bucket is a token bucket for a single key
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limit is a set of token buckets sharing a rate
+type limit struct {
+	rate    rate
+	buckets map[string]*bucket
+	// pruned is when buckets were last swept for ones that refilled
+	pruned time.Time
+}
+
+// prune drops buckets that have sat idle long enough to refill
+// a missing bucket starts out full so dropping them changes nothing
+// it sweeps at most once per rate period to keep allow cheap
+func (l *limit) prune(now time.Time) {
+	if now.Sub(l.pruned) < l.rate.per {
+		return
+	}
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.rate.per {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.pruned = now
+}
+
+// refill tops up key's bucket and returns it
+func (l *limit) refill(key string, now time.Time) *bucket {
+	b, ok := l.buckets[key]
+
+	if !ok {
+		b = &bucket{tokens: float64(l.rate.burst), last: now}
+		l.buckets[key] = b
+	}
+
+	refillRate := float64(l.rate.burst) / l.rate.per.Seconds()
+
+	b.tokens = math.Min(float64(l.rate.burst), b.tokens+now.Sub(b.last).Seconds()*refillRate)
+	b.last = now
+
+	return b
+}
+
+// wait returns how long until key has a token available
+func (l *limit) wait(key string, now time.Time) time.Duration {
+	b := l.refill(key, now)
+
+	if b.tokens >= 1 {
+		return 0
+	}
+
+	refillRate := float64(l.rate.burst) / l.rate.per.Seconds()
+
+	return time.Duration((1 - b.tokens) / refillRate * float64(time.Second))
+}
+
+// rateLimiter limits commands per user, per channel and per user per command
+type rateLimiter struct {
+	mu       sync.Mutex
+	user     *limit
+	channel  *limit
+	commands map[string]*limit
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		commands: map[string]*limit{},
+	}
+}
+
+// configure sets the rates used by the limiter
+// an empty rate turns that limit off
+// commandRates is a comma separated list like `search=5/1m,discover=2/1m`
+func (r *rateLimiter) configure(userRate, channelRate, commandRates string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	newLimit := func(str string) (*limit, error) {
+		if str == "" {
+			return nil, nil
+		}
+
+		parsed, err := parseRate(str)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &limit{rate: parsed, buckets: map[string]*bucket{}}, nil
+	}
+
+	var err error
+
+	if r.user, err = newLimit(userRate); err != nil {
+		return err
+	}
+
+	if r.channel, err = newLimit(channelRate); err != nil {
+		return err
+	}
+
+	r.commands = map[string]*limit{}
+
+	for _, commandRate := range strings.Split(commandRates, ",") {
+		commandRate = strings.TrimSpace(commandRate)
+
+		if commandRate == "" {
+			continue
+		}
+
+		parts := strings.SplitN(commandRate, "=", 2)
+
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid command rate %q: should look like search=5/1m", commandRate)
+		}
+
+		if r.commands[parts[0]], err = newLimit(parts[1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// allow takes a token for a command if every limit that applies has one
+// otherwise it returns how long to wait and which limit was hit
+func (r *rateLimiter) allow(userID, channelID, cmd string, now time.Time) (time.Duration, string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	type check struct {
+		scope string
+		limit *limit
+		key   string
+	}
+
+	checks := []check{
+		{"user", r.user, userID},
+		{"channel", r.channel, channelID},
+		{"command", r.commands[cmd], userID + ":" + cmd},
+	}
+
+	longestWait := time.Duration(0)
+	scope := ""
+
+	for _, c := range checks {
+		if c.limit == nil {
+			continue
+		}
+
+		c.limit.prune(now)
+
+		if wait := c.limit.wait(c.key, now); wait > longestWait {
+			longestWait = wait
+			scope = c.scope
+		}
+	}
+
+	if longestWait > 0 {
+		return longestWait, scope
+	}
+
+	// only spend tokens once we know the command is allowed
+	for _, c := range checks {
+		if c.limit != nil {
+			c.limit.buckets[c.key].tokens--
+		}
+	}
+
+	return 0, ""
+}
+
+// rateLimitedMessage tells the user how long to wait before trying again
+func rateLimitedMessage(cmd string, wait time.Duration) string {
+	seconds := int(math.Ceil(wait.Seconds()))
+
+	return fmt.Sprintf("slow down! try `%s` again in %ds", cmd, seconds)
+}
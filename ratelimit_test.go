@@ -0,0 +1,150 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterPrunesIdleBuckets(t *testing.T) {
+	limiter := newRateLimiter()
+
+	if err := limiter.configure("2/1m", "", "search=1/1m"); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+
+	for i := 0; i < 100; i++ {
+		if wait, _ := limiter.allow(strconv.Itoa(i), "100", "search", start); wait != 0 {
+			t.Fatalf("user %d was limited on their first command", i)
+		}
+	}
+
+	if got := len(limiter.user.buckets); got != 100 {
+		t.Fatalf("user buckets = %d, want 100", got)
+	}
+
+	// user 0 is still limited on search until a minute has passed
+	if wait, scope := limiter.allow("0", "100", "search", start.Add(30*time.Second)); wait == 0 || scope != "command" {
+		t.Errorf("allow = %v %q, want a command wait", wait, scope)
+	}
+
+	later := start.Add(2 * time.Minute)
+
+	if wait, _ := limiter.allow("100", "100", "search", later); wait != 0 {
+		t.Fatal("new user was limited")
+	}
+
+	if got := len(limiter.user.buckets); got != 1 {
+		t.Errorf("user buckets after idling = %d, want 1", got)
+	}
+
+	if got := len(limiter.commands["search"].buckets); got != 1 {
+		t.Errorf("search buckets after idling = %d, want 1", got)
+	}
+
+	// a pruned user gets a full bucket back
+	for i := 0; i < 2; i++ {
+		if wait, _ := limiter.allow("0", "100", "status", later); wait != 0 {
+			t.Errorf("command %d after pruning was limited", i+1)
+		}
+	}
+
+	if wait, scope := limiter.allow("0", "100", "status", later); wait == 0 || scope != "user" {
+		t.Errorf("third command = %v %q, want a user wait", wait, scope)
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	type call struct {
+		user, channel, cmd string
+		// after is how long after the first call this one is made
+		after     time.Duration
+		wantWait  time.Duration
+		wantScope string
+	}
+
+	tests := []struct {
+		name                                string
+		userRate, channelRate, commandRates string
+		calls                               []call
+	}{
+		{
+			name:     "burst exhausted",
+			userRate: "2/1m",
+			calls: []call{
+				{user: "1", channel: "100", cmd: "search"},
+				{user: "1", channel: "100", cmd: "status"},
+				{user: "1", channel: "100", cmd: "search", wantWait: 30 * time.Second, wantScope: "user"},
+				{user: "1", channel: "100", cmd: "search", after: 20 * time.Second, wantWait: 10 * time.Second, wantScope: "user"},
+			},
+		},
+		{
+			name:     "refills after the interval",
+			userRate: "2/1m",
+			calls: []call{
+				{user: "1", channel: "100", cmd: "search"},
+				{user: "1", channel: "100", cmd: "search"},
+				{user: "1", channel: "100", cmd: "search", after: 30 * time.Second},
+				{user: "1", channel: "100", cmd: "search", after: 30 * time.Second, wantWait: 30 * time.Second, wantScope: "user"},
+				{user: "1", channel: "100", cmd: "search", after: 2 * time.Minute},
+				{user: "1", channel: "100", cmd: "search", after: 2 * time.Minute},
+			},
+		},
+		{
+			name:     "separate buckets per user",
+			userRate: "1/1m",
+			calls: []call{
+				{user: "1", channel: "100", cmd: "search"},
+				{user: "2", channel: "100", cmd: "search"},
+				{user: "1", channel: "100", cmd: "search", wantWait: time.Minute, wantScope: "user"},
+				{user: "3", channel: "100", cmd: "search"},
+			},
+		},
+		{
+			name:        "channel shared by its users",
+			userRate:    "5/1m",
+			channelRate: "2/1m",
+			calls: []call{
+				{user: "1", channel: "100", cmd: "search"},
+				{user: "2", channel: "100", cmd: "search"},
+				{user: "3", channel: "100", cmd: "search", wantWait: 30 * time.Second, wantScope: "channel"},
+				{user: "3", channel: "200", cmd: "search"},
+			},
+		},
+		{
+			name:         "command per user",
+			commandRates: "search=1/1m",
+			calls: []call{
+				{user: "1", channel: "100", cmd: "search"},
+				{user: "1", channel: "100", cmd: "status"},
+				{user: "1", channel: "100", cmd: "search", wantWait: time.Minute, wantScope: "command"},
+				{user: "2", channel: "100", cmd: "search"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := newRateLimiter()
+
+			if err := limiter.configure(tt.userRate, tt.channelRate, tt.commandRates); err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+
+			for i, c := range tt.calls {
+				wait, scope := limiter.allow(c.user, c.channel, c.cmd, start.Add(c.after))
+
+				if wait.Round(time.Millisecond) != c.wantWait || scope != c.wantScope {
+					t.Errorf("call %d (user %s, %s after) = %v %q, want %v %q",
+						i+1, c.user, c.after, wait, scope, c.wantWait, c.wantScope)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// releases.go lets users pick a release by hand when automatic search grabs the wrong one
+
+const (
+	// searching every indexer takes a while
+	releaseSearchTimeout = 2 * time.Minute
+	maxListedReleases    = 10
+)
+
+// release is a candidate download radarr or sonarr found on an indexer
+type release struct {
+	GUID      string  `json:"guid"`
+	Title     string  `json:"title"`
+	Indexer   string  `json:"indexer"`
+	IndexerID int     `json:"indexerId"`
+	Size      int64   `json:"size"`
+	Seeders   *int    `json:"seeders"`
+	Protocol  string  `json:"protocol"`
+	AgeHours  float64 `json:"ageHours"`
+	Quality   struct {
+		Quality struct {
+			Name string `json:"name"`
+		} `json:"quality"`
+	} `json:"quality"`
+	Rejected   bool     `json:"rejected"`
+	Rejections []string `json:"rejections"`
+}
+
+// age formats how long ago a release was posted
+func (r release) age() string {
+	if r.AgeHours < 48 {
+		return fmt.Sprintf("%.0fh", r.AgeHours)
+	}
+
+	return fmt.Sprintf("%.0fd", r.AgeHours/24)
+}
+
+// releaseListing is the last list of releases shown in a channel
+type releaseListing struct {
+	api arrAPI
+	// media names what the releases are for, e.g. `Sicario (2015)`
+	media    string
+	releases []release
+}
+
+// listedReleases remembers what `releases` showed in each channel so
+// `grab` can refer to a release by its index
+var listedReleases = struct {
+	sync.Mutex
+	byChannel map[string]releaseListing
+}{byChannel: map[string]releaseListing{}}
+
+func formatRelease(index int, r release) string {
+	seeders := "-"
+
+	if r.Seeders != nil {
+		seeders = strconv.Itoa(*r.Seeders)
+	}
+
+	line := fmt.Sprintf("`%d` %s\n    %s | %s | %s | %s seeders | %s",
+		index,
+		truncate(r.Title, 80),
+		r.Indexer,
+		r.Quality.Quality.Name,
+		formatBytes(r.Size),
+		seeders,
+		r.age())
+
+	if r.Rejected {
+		line += "\n    rejected: " + truncate(strings.Join(r.Rejections, "; "), 120)
+	}
+
+	return line + "\n"
+}
+
+func (backend radarrBackend) ReleaseSearch(args []string) (string, url.Values, error) {
+	movie, err := libraryItem(backend, "movie", strings.TrimSpace(strings.Join(args, " ")))
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	params := url.Values{}
+	params.Set("movieId", strconv.Itoa(movie.libraryID))
+
+	return fmt.Sprintf("%s (%d)", movie.title, movie.year), params, nil
+}
+
+func (backend sonarrBackend) ReleaseSearch(args []string) (string, url.Values, error) {
+	input, season, episode, err := splitEpisodeArgs(args)
+
+	if err != nil {
+		return "", nil, usageError{err}
+	}
+
+	if episode < 0 {
+		return "", nil, usageError{errors.New("releases are listed per episode")}
+	}
+
+	show, err := libraryItem(backend, "show", input)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	ep, err := findEpisode(backend.services, show, season, episode)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	params := url.Values{}
+	params.Set("episodeId", strconv.Itoa(ep.ID))
+
+	return fmt.Sprintf("%s S%02dE%02d", show.title, ep.SeasonNumber, ep.EpisodeNumber), params, nil
+}
+
+func showReleases(commandList d, services clients) func(channelID string, args ...string) {
+	return func(channelID string, args ...string) {
+		// command: releases movie <tmdb-id|title>
+		//          releases show <tvdb-id|title> S02E05
+		usage := "`releases movie <tmdb-id|title>` or `releases show <tvdb-id|title> S02E05`"
+
+		if len(args) < 2 {
+			commandList.showError(channelID, usage)
+			return
+		}
+
+		backend, ok := mediaBackendFor(commandList, services, channelID, resolveMediaType(args[0]))
+
+		if !ok {
+			return
+		}
+
+		name, params, err := backend.ReleaseSearch(args[1:])
+
+		if _, ok := err.(usageError); ok {
+			commandList.showError(channelID, fmt.Sprintf("%v: %s", err, usage))
+			return
+		} else if err != nil {
+			commandList.showError(channelID, err.Error())
+			return
+		}
+
+		listing := releaseListing{api: backend.API(), media: name}
+
+		if err := listing.api.withTimeout(releaseSearchTimeout).get("/release", params, &listing.releases); err != nil {
+			channelLogger(channelID).Error("fetch releases failed", "backend", listing.api.name, "error", err)
+			commandList.showError(channelID, fmt.Sprintf("%s could not search for releases: %v", listing.api.name, err))
+			return
+		}
+
+		if len(listing.releases) == 0 {
+			commandList.send(channelID, fmt.Sprintf("no releases found for `%s`", listing.media))
+			return
+		}
+
+		output := fmt.Sprintf("%d releases for `%s`:\n", len(listing.releases), listing.media)
+		shown := len(listing.releases)
+
+		for i, r := range listing.releases {
+			line := formatRelease(i+1, r)
+
+			if i == maxListedReleases || len(output)+len(line) > 1800 {
+				output += fmt.Sprintf("...and %d more\n", len(listing.releases)-i)
+				shown = i
+				break
+			}
+
+			output += line
+		}
+
+		output += "\nadmins can download one with `grab <number>`"
+
+		// only the releases users could see can be grabbed
+		listing.releases = listing.releases[:shown]
+
+		listedReleases.Lock()
+		listedReleases.byChannel[channelID] = listing
+		listedReleases.Unlock()
+
+		commandList.send(channelID, output)
+	}
+}
+
+func grabRelease(commandList d, services clients) func(channelID string, args ...string) {
+	return func(channelID string, args ...string) {
+		// command: grab <number>
+		listedReleases.Lock()
+		listing, ok := listedReleases.byChannel[channelID]
+		listedReleases.Unlock()
+
+		if !ok {
+			commandList.showError(channelID, "list releases first with `releases movie <tmdb-id>` or `releases show <tvdb-id> S02E05`")
+			return
+		}
+
+		if len(args) != 1 {
+			commandList.showError(channelID, "`grab <number>`")
+			return
+		}
+
+		// grab's arguments don't say which backend it talks to, the listing does
+		if reason := commandList.backends.commandDisabled("grab", listing.api.name); reason != "" {
+			commandList.showError(channelID, reason)
+			return
+		}
+
+		index, err := strconv.Atoi(args[0])
+
+		if err != nil || index < 1 || index > len(listing.releases) {
+			commandList.showError(channelID, fmt.Sprintf("pick a release between 1 and %d", len(listing.releases)))
+			return
+		}
+
+		chosen := listing.releases[index-1]
+
+		var grabbed release
+
+		payload := map[string]interface{}{
+			"guid":      chosen.GUID,
+			"indexerId": chosen.IndexerID,
+		}
+
+		if err := listing.api.withTimeout(releaseSearchTimeout).post("/release", payload, &grabbed); err != nil {
+			channelLogger(channelID).Error("grab release failed", "backend", listing.api.name, "error", err)
+			commandList.showError(channelID, fmt.Sprintf("%s could not grab `%s`: %v", listing.api.name, chosen.Title, err))
+			return
+		}
+
+		commandList.send(channelID, fmt.Sprintf("grabbed `%s` from %s for `%s`", chosen.Title, chosen.Indexer, listing.media))
+	}
+}
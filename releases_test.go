@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReleases(t *testing.T) {
+	runCommandCases(t, "releases", []commandCase{
+		{
+			name: "movie",
+			args: []string{"movie", "sicario", "2015"},
+			want: []string{"2 releases for `Sicario (2015)`:\n" +
+				"`1` Sicario.2015.1080p.BluRay.x264-SPARKS\n" +
+				"    Example Indexer | Bluray-1080p | 8.0 GB | 120 seeders | 30h\n" +
+				"`2` Sicario.2015.2160p.UHD.BluRay.x265-TERMiNAL\n" +
+				"    Example Indexer | Bluray-2160p | 30.0 GB | 45 seeders | 40d\n" +
+				"    rejected: Not an upgrade for existing movie file(s)\n" +
+				"\nadmins can download one with `grab <number>`"},
+			request: "radarr GET /api/v3/release",
+			query:   map[string]string{"movieId": "1"},
+		},
+		{
+			name: "episode",
+			args: []string{"show", "breaking", "bad", "S02E05"},
+			want: []string{"1 releases for `Breaking Bad S02E05`:\n" +
+				"`1` Breaking.Bad.S02E05.Breakage.1080p.BluRay.x264-ROVERS\n" +
+				"    Example Usenet | Bluray-1080p | 1.5 GB | - seeders | 5h\n" +
+				"\nadmins can download one with `grab <number>`"},
+			request: "sonarr GET /api/v3/release",
+			query:   map[string]string{"episodeId": "105"},
+		},
+		{
+			name: "nothing found",
+			args: []string{"movie", "273481"},
+			setup: func(h *harness) {
+				h.radarr.respond("GET /api/v3/release?movieId=1", 200, "[]")
+			},
+			want: []string{"no releases found for `Sicario (2015)`"},
+		},
+		{
+			name:  "movie not in library",
+			args:  []string{"movie", "400535"},
+			want:  []string{"`400535` is not in your library, `add movie 400535` first"},
+			check: nothingSent("radarr", "GET", "/api/v3/release"),
+		},
+		{
+			name: "whole season",
+			args: []string{"show", "81189", "S02"},
+			want: []string{"releases are listed per episode: `releases movie <tmdb-id|title>` or `releases show <tvdb-id|title> S02E05`"},
+		},
+		{
+			name: "indexers failing",
+			args: []string{"movie", "273481"},
+			setup: func(h *harness) {
+				h.radarr.respond("GET /api/v3/release?movieId=1", 500, "")
+			},
+			want: []string{"radarr could not search for releases: 500 Internal Server Error"},
+		},
+	})
+}
+
+func TestGrab(t *testing.T) {
+	listed := func(args ...string) func(h *harness) {
+		return func(h *harness) {
+			h.run("releases", args...)
+		}
+	}
+
+	runCommandCases(t, "grab", []commandCase{
+		{
+			name:    "movie release",
+			args:    []string{"1"},
+			setup:   listed("movie", "273481"),
+			want:    []string{"grabbed `Sicario.2015.1080p.BluRay.x264-SPARKS` from Example Indexer for `Sicario (2015)`"},
+			request: "radarr POST /api/v3/release",
+			body: map[string]interface{}{
+				"guid":      "https://indexer.example/details/1001",
+				"indexerId": float64(3),
+			},
+		},
+		{
+			name:    "episode release",
+			args:    []string{"1"},
+			setup:   listed("show", "81189", "S02E05"),
+			want:    []string{"grabbed `Breaking.Bad.S02E05.Breakage.1080p.BluRay.x264-ROVERS` from Example Usenet for `Breaking Bad S02E05`"},
+			request: "sonarr POST /api/v3/release",
+			body: map[string]interface{}{
+				"guid":      "https://nzb.example/details/77",
+				"indexerId": float64(5),
+			},
+		},
+		{
+			name:  "out of range",
+			args:  []string{"3"},
+			setup: listed("movie", "273481"),
+			want:  []string{"pick a release between 1 and 2"},
+			check: nothingSent("radarr", "POST", "/api/v3/release"),
+		},
+		{
+			name: "beyond the listed releases",
+			args: []string{"11"},
+			setup: func(h *harness) {
+				releases := make([]string, maxListedReleases+2)
+
+				for i := range releases {
+					releases[i] = fmt.Sprintf(`{"guid": "guid-%d", "title": "Sicario.2015.%d", "indexerId": 3}`, i, i)
+				}
+
+				h.radarr.respond("GET /api/v3/release?movieId=1", 200, "["+strings.Join(releases, ",")+"]")
+				listed("movie", "273481")(h)
+			},
+			want:  []string{"pick a release between 1 and 10"},
+			check: nothingSent("radarr", "POST", "/api/v3/release"),
+		},
+		{
+			name: "download client failing",
+			args: []string{"2"},
+			setup: func(h *harness) {
+				listed("movie", "273481")(h)
+				h.radarr.respond("POST /api/v3/release", 500, "")
+			},
+			want: []string{"radarr could not grab `Sicario.2015.2160p.UHD.BluRay.x265-TERMiNAL`: 500 Internal Server Error"},
+		},
+		{
+			name: "backend down since listing",
+			args: []string{"1"},
+			setup: func(h *harness) {
+				listed("movie", "273481")(h)
+
+				h.backends.mu.Lock()
+				h.backends.unavailable["radarr"] = "502 Bad Gateway"
+				h.backends.mu.Unlock()
+			},
+			want:  []string{"`grab` is disabled because radarr could not be reached: 502 Bad Gateway"},
+			check: nothingSent("radarr", "POST", "/api/v3/release"),
+		},
+		{
+			name: "nothing listed",
+			args: []string{"1"},
+			want: []string{"list releases first with `releases movie <tmdb-id>` or `releases show <tvdb-id> S02E05`"},
+		},
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// seriespath.go builds the folder a show is added to
+
+// seriesFolderFormat names a show's folder, e.g. `{Title} ({Year})` or `{Title} [tvdb-{TvdbId}]`
+var seriesFolderFormat string
+
+var (
+	seriesFolderTokenPattern = regexp.MustCompile(`\{(\w+)\}`)
+	repeatedSpacePattern     = regexp.MustCompile(`\s+`)
+)
+
+// illegalPathCharacters are replaced the same way sonarr does when it names folders
+var illegalPathCharacters = strings.NewReplacer(
+	`\`, "+",
+	"/", "+",
+	"<", "",
+	">", "",
+	"?", "!",
+	"*", "-",
+	": ", " - ",
+	":", "-",
+	"|", "",
+	`"`, "",
+)
+
+// validateSeriesFolderFormat makes sure a format only uses tokens we know
+func validateSeriesFolderFormat(format string) error {
+	if strings.TrimSpace(format) == "" {
+		return fmt.Errorf("the series folder format can't be empty")
+	}
+
+	for _, match := range seriesFolderTokenPattern.FindAllStringSubmatch(format, -1) {
+		switch match[1] {
+		case "Title", "Year", "TvdbId":
+		default:
+			return fmt.Errorf("unknown token %s in series folder format, use {Title}, {Year} or {TvdbId}", match[0])
+		}
+	}
+
+	return nil
+}
+
+// cleanFolderName removes characters filesystems don't allow and tidies up spacing
+func cleanFolderName(name string) string {
+	name = illegalPathCharacters.Replace(name)
+	name = repeatedSpacePattern.ReplaceAllString(name, " ")
+
+	// windows won't have folders ending in a dot or space
+	return strings.TrimRight(strings.TrimSpace(name), ". ")
+}
+
+// seriesFolderName fills in a folder format for a show. A name with nothing
+// left once it's cleaned would put the show in the root folder itself
+func seriesFolderName(format, title string, year, tvdbID int) (string, error) {
+	name := seriesFolderTokenPattern.ReplaceAllStringFunc(format, func(token string) string {
+		switch token {
+		case "{Title}":
+			return title
+		case "{Year}":
+			return strconv.Itoa(year)
+		case "{TvdbId}":
+			return strconv.Itoa(tvdbID)
+		}
+
+		return token
+	})
+
+	name = cleanFolderName(name)
+
+	if name == "" {
+		return "", fmt.Errorf("`%s` leaves no folder name with the series folder format `%s`", title, format)
+	}
+
+	return name, nil
+}
+
+// joinSeriesPath puts a folder inside a root folder using the root's own
+// separator since sonarr may run on windows
+func joinSeriesPath(root, folder string) string {
+	separator := "/"
+
+	if strings.Contains(root, `\`) && !strings.Contains(root, "/") {
+		separator = `\`
+	}
+
+	return strings.TrimRight(root, `/\`) + separator + folder
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// server.go serves shart's operational endpoints over http
+
+// httpAddr is the address the http server listens on
+var httpAddr string
+
+func newHTTPServer(addr string, backends *backendState) *http.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/metrics", metricsHandler)
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/readyz", readyzHandler(backends))
+
+	return &http.Server{
+		Addr:         addr,
+		Handler:      mux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 15 * time.Second,
+	}
+}
+
+// serveHTTP starts the http server in the background
+func serveHTTP(server *http.Server) {
+	go func() {
+		logger.Info("http server listening", "addr", server.Addr)
+
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("http server failed", "error", err)
+		}
+	}()
+}
+
+// healthReport is the body of /healthz and /readyz
+type healthReport struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+func writeHealthReport(w http.ResponseWriter, report healthReport) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if report.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
+
+// healthzHandler reports that the process is alive
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, healthReport{Status: "ok"})
+}
+
+// readyzHandler reports whether shart can do its job: it is connected to
+// discord and both radarr and sonarr answer their system status endpoint
+func readyzHandler(backends *backendState) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := healthReport{
+			Status: "ok",
+			Checks: map[string]string{},
+		}
+
+		check := func(name string, err error) {
+			if err != nil {
+				report.Status = "unavailable"
+				report.Checks[name] = err.Error()
+				return
+			}
+
+			report.Checks[name] = "ok"
+		}
+
+		var gatewayErr error
+
+		if gatewayConnected.get() != 1 {
+			gatewayErr = fmt.Errorf("not connected to the discord gateway")
+		}
+
+		check("discord", gatewayErr)
+
+		services := backends.clients()
+
+		for _, backend := range services.mediaBackends() {
+			_, err := backend.API().systemStatus()
+
+			check(backend.Name(), err)
+		}
+
+		writeHealthReport(w, report)
+	}
+}
+
+// runHealthcheck queries the health endpoints of a shart listening on addr
+// and returns the exit code for -healthcheck. It lets the scratch docker
+// image define a HEALTHCHECK without shipping curl
+func runHealthcheck(addr string) int {
+	host, port, err := net.SplitHostPort(addr)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid http address %q: %v\n", addr, err)
+		return 1
+	}
+
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+
+	client := http.Client{
+		Timeout: 10 * time.Second,
+	}
+
+	for _, endpoint := range []string{"/healthz", "/readyz"} {
+		resp, err := client.Get("http://" + net.JoinHostPort(host, port) + endpoint)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", endpoint, err)
+			return 1
+		}
+
+		var report healthReport
+
+		json.NewDecoder(resp.Body).Decode(&report)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			fmt.Fprintf(os.Stderr, "%s: %s %v\n", endpoint, resp.Status, report.Checks)
+			return 1
+		}
+	}
+
+	fmt.Println("ok")
+
+	return 0
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// settings.go finds quality profiles and root folders by what users call them
+
+// namedID is a quality or language profile to choose from
+type namedID struct {
+	id   int
+	name string
+}
+
+// pickNamed finds the item whose id or name is input. Names are matched
+// regardless of case and punctuation so `hd 1080p` finds `HD-1080p`, and
+// the start of a name is enough when only one item begins that way.
+// Anything else is an error listing the candidates rather than a guess
+func pickNamed(items []namedID, input, kind string) (namedID, error) {
+	if id, err := strconv.Atoi(input); err == nil {
+		for _, item := range items {
+			if item.id == id {
+				return item, nil
+			}
+		}
+
+		return namedID{}, fmt.Errorf("could not find a %s with id `%d`%s", kind, id, listNamed(items))
+	}
+
+	for _, item := range items {
+		if strings.EqualFold(item.name, input) {
+			return item, nil
+		}
+	}
+
+	// compare names without case or punctuation
+	normalizedInput := normalizeTitle(input)
+
+	for _, item := range items {
+		if normalizeTitle(item.name) == normalizedInput {
+			return item, nil
+		}
+	}
+
+	var prefixed []namedID
+
+	for _, item := range items {
+		if normalizedInput != "" && strings.HasPrefix(normalizeTitle(item.name), normalizedInput) {
+			prefixed = append(prefixed, item)
+		}
+	}
+
+	switch len(prefixed) {
+	case 1:
+		return prefixed[0], nil
+	case 0:
+		return namedID{}, fmt.Errorf("could not find a %s named `%s`%s", kind, input, listNamed(items))
+	default:
+		return namedID{}, fmt.Errorf("`%s` could be more than one %s%s", input, kind, listNamed(prefixed))
+	}
+}
+
+// listNamed lists the items a user could have meant
+func listNamed(items []namedID) string {
+	if len(items) == 0 {
+		return ""
+	}
+
+	var names []string
+
+	for _, item := range items {
+		names = append(names, fmt.Sprintf("`%s`", item.name))
+	}
+
+	return ", try one of " + strings.Join(names, ", ")
+}
+
+// rootFolder is a radarr or sonarr root folder
+type rootFolder struct {
+	id        int
+	path      string
+	freeSpace int64
+}
+
+func (folder rootFolder) String() string {
+	return fmt.Sprintf("`%s` (%s free)", folder.path, formatBytes(folder.freeSpace))
+}
+
+// trimSeparators drops trailing slashes so `/movies/` matches `/movies`
+func trimSeparators(path string) string {
+	if trimmed := strings.TrimRight(path, `/\`); trimmed != "" {
+		return trimmed
+	}
+
+	return path
+}
+
+// pickRootFolder finds the root folder whose id or path is input.
+// Only folders radarr or sonarr know about can be picked
+func pickRootFolder(folders []rootFolder, input string) (rootFolder, error) {
+	id, err := strconv.Atoi(input)
+
+	for _, folder := range folders {
+		if err == nil && folder.id == id {
+			return folder, nil
+		}
+
+		if trimSeparators(folder.path) == trimSeparators(input) {
+			return folder, nil
+		}
+	}
+
+	// windows paths don't care about case
+	for _, folder := range folders {
+		if strings.EqualFold(trimSeparators(folder.path), trimSeparators(input)) {
+			return folder, nil
+		}
+	}
+
+	output := fmt.Sprintf("`%s` is not a root folder", input)
+
+	if len(folders) > 0 {
+		var paths []string
+
+		for _, folder := range folders {
+			paths = append(paths, fmt.Sprintf("`%d` %s", folder.id, folder))
+		}
+
+		output += ", pick one of:\n" + strings.Join(paths, "\n")
+	} else {
+		output += ", add root folders in the web ui first"
+	}
+
+	return rootFolder{}, fmt.Errorf("%s", output)
+}
+
+// fetchMovieFolders returns radarr's root folders
+func fetchMovieFolders(services clients) ([]rootFolder, error) {
+	folders, err := services.radarr.GetRootFolders()
+
+	if err != nil {
+		return nil, fmt.Errorf("fetch radarr root folders failed: %v", err)
+	}
+
+	items := make([]rootFolder, len(folders))
+
+	for i, folder := range folders {
+		items[i] = rootFolder{id: folder.ID, path: folder.Path, freeSpace: int64(folder.FreeSpace)}
+	}
+
+	return items, nil
+}
+
+// fetchShowFolders returns sonarr's root folders
+func fetchShowFolders(services clients) ([]rootFolder, error) {
+	folders, err := services.sonarr.GetRootFolders()
+
+	if err != nil {
+		return nil, fmt.Errorf("fetch sonarr root folders failed: %v", err)
+	}
+
+	items := make([]rootFolder, len(folders))
+
+	for i, folder := range folders {
+		items[i] = rootFolder{id: folder.ID, path: folder.Path, freeSpace: int64(folder.FreeSpace)}
+	}
+
+	return items, nil
+}
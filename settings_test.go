@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestPickNamed(t *testing.T) {
+	profiles := []namedID{
+		{1, "Any"},
+		{2, "SD"},
+		{3, "HD-720p"},
+		{4, "HD - 720p/1080p"},
+		{5, "Ultra-HD"},
+	}
+
+	const candidates = ", try one of `Any`, `SD`, `HD-720p`, `HD - 720p/1080p`, `Ultra-HD`"
+
+	cases := []struct {
+		input   string
+		want    int
+		wantErr string
+	}{
+		{input: "4", want: 4},
+		{input: "hd-720p", want: 3},
+		{input: "hd 720p", want: 3},
+		{input: "HD 720p 1080p", want: 4},
+		{input: "ultra", want: 5},
+		{input: "9", wantErr: "could not find a quality profile with id `9`" + candidates},
+		{input: "hd-1080p", wantErr: "could not find a quality profile named `hd-1080p`" + candidates},
+		{input: "HD-1080p", wantErr: "could not find a quality profile named `HD-1080p`" + candidates},
+		{input: "hd 1080p", wantErr: "could not find a quality profile named `hd 1080p`" + candidates},
+		{input: "uhd", wantErr: "could not find a quality profile named `uhd`" + candidates},
+		{input: "hd", wantErr: "`hd` could be more than one quality profile, try one of `HD-720p`, `HD - 720p/1080p`"},
+		{input: "-", wantErr: "could not find a quality profile named `-`" + candidates},
+	}
+
+	for _, c := range cases {
+		got, err := pickNamed(profiles, c.input, "quality profile")
+
+		if c.wantErr != "" {
+			if err == nil || err.Error() != c.wantErr {
+				t.Errorf("pickNamed(%q) = %+v, %v, want error %q", c.input, got, err, c.wantErr)
+			}
+
+			continue
+		}
+
+		if err != nil || got.id != c.want {
+			t.Errorf("pickNamed(%q) = %+v, %v, want id %d", c.input, got, err, c.want)
+		}
+	}
+}
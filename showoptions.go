@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	sonarr "github.com/jrudio/go-sonarr-client"
+)
+
+// showoptions.go covers the sonarr settings that decide how a show is added:
+// series type, language profile and season folders
+
+// seriesTypes are the ways sonarr can number episodes
+var seriesTypes = []string{"standard", "daily", "anime"}
+
+// showDefaults are a channel's settings for adding shows
+type showDefaults struct {
+	seriesType string
+	// languageProfileID is left to sonarr when zero
+	languageProfileID int
+	languageProfile   string
+	seasonFolders     bool
+}
+
+// channelShowDefaults holds the show defaults each channel changed with `defaults show`
+// they're saved to path so they survive a restart
+var channelShowDefaults = struct {
+	sync.Mutex
+	path      string
+	byChannel map[string]showDefaults
+}{byChannel: map[string]showDefaults{}}
+
+// savedShowDefaults is how a channel's show defaults are written to disk
+type savedShowDefaults struct {
+	SeriesType        string `json:"series_type"`
+	LanguageProfileID int    `json:"language_profile_id"`
+	LanguageProfile   string `json:"language_profile"`
+	SeasonFolders     bool   `json:"season_folders"`
+}
+
+// loadShowDefaults reads the show defaults channels saved in dataDir
+func loadShowDefaults(dataDir string) error {
+	channelShowDefaults.Lock()
+	defer channelShowDefaults.Unlock()
+
+	channelShowDefaults.path = filepath.Join(dataDir, "show_defaults.json")
+	channelShowDefaults.byChannel = map[string]showDefaults{}
+
+	contents, err := ioutil.ReadFile(channelShowDefaults.path)
+
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var saved map[string]savedShowDefaults
+
+	if err := json.Unmarshal(contents, &saved); err != nil {
+		return fmt.Errorf("corrupt %s: %v", channelShowDefaults.path, err)
+	}
+
+	for channelID, defaults := range saved {
+		channelShowDefaults.byChannel[channelID] = showDefaults{
+			seriesType:        defaults.SeriesType,
+			languageProfileID: defaults.LanguageProfileID,
+			languageProfile:   defaults.LanguageProfile,
+			seasonFolders:     defaults.SeasonFolders,
+		}
+	}
+
+	return nil
+}
+
+// saveShowDefaults writes every channel's show defaults to disk with a channel's
+// changed ones. The change only applies once it's saved
+func saveShowDefaults(channelID string, defaults showDefaults) error {
+	channelShowDefaults.Lock()
+	defer channelShowDefaults.Unlock()
+
+	saved := map[string]savedShowDefaults{}
+
+	for id, channelDefaults := range channelShowDefaults.byChannel {
+		saved[id] = newSavedShowDefaults(channelDefaults)
+	}
+
+	saved[channelID] = newSavedShowDefaults(defaults)
+
+	contents, err := json.Marshal(saved)
+
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(channelShowDefaults.path, contents); err != nil {
+		return err
+	}
+
+	channelShowDefaults.byChannel[channelID] = defaults
+
+	return nil
+}
+
+func newSavedShowDefaults(defaults showDefaults) savedShowDefaults {
+	return savedShowDefaults{
+		SeriesType:        defaults.seriesType,
+		LanguageProfileID: defaults.languageProfileID,
+		LanguageProfile:   defaults.languageProfile,
+		SeasonFolders:     defaults.seasonFolders,
+	}
+}
+
+// showDefaultsFor returns the show defaults of a channel
+func showDefaultsFor(channelID string) showDefaults {
+	channelShowDefaults.Lock()
+	defer channelShowDefaults.Unlock()
+
+	if defaults, ok := channelShowDefaults.byChannel[channelID]; ok {
+		return defaults
+	}
+
+	return showDefaults{seriesType: "standard", seasonFolders: true}
+}
+
+func (defaults showDefaults) String() string {
+	language := "sonarr's default"
+
+	if defaults.languageProfileID != 0 {
+		language = fmt.Sprintf("%s `%d`", defaults.languageProfile, defaults.languageProfileID)
+	}
+
+	return fmt.Sprintf("type: `%s`, language: %s, season folders: `%t`",
+		defaults.seriesType,
+		language,
+		defaults.seasonFolders)
+}
+
+// resolveSeriesType matches a series type regardless of case
+func resolveSeriesType(seriesType string) (string, error) {
+	for _, valid := range seriesTypes {
+		if strings.EqualFold(seriesType, valid) {
+			return valid, nil
+		}
+	}
+
+	return "", fmt.Errorf("`%s` is not a series type, use one of %s", seriesType, strings.Join(seriesTypes, ", "))
+}
+
+// languageProfile is a sonarr v3 language profile
+type languageProfile struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// fetchLanguageProfiles returns sonarr's language profiles
+func fetchLanguageProfiles(services clients) ([]languageProfile, error) {
+	var profiles []languageProfile
+
+	if err := services.sonarrAPI.withRoot(v3APIRoot).get("/languageprofile", nil, &profiles); err != nil {
+		return nil, fmt.Errorf("fetch sonarr language profiles failed (they need sonarr v3): %v", err)
+	}
+
+	return profiles, nil
+}
+
+// resolveLanguageProfile finds a sonarr language profile given its name or id
+func resolveLanguageProfile(services clients, nameOrID string) (namedID, error) {
+	profiles, err := fetchLanguageProfiles(services)
+
+	if err != nil {
+		return namedID{}, err
+	}
+
+	items := make([]namedID, len(profiles))
+
+	for i, profile := range profiles {
+		items[i] = namedID{id: profile.ID, name: profile.Name}
+	}
+
+	return pickNamed(items, nameOrID, "language profile")
+}
+
+// applyShowOptions overrides a channel's show defaults with the flags given to a command
+func applyShowOptions(services clients, defaults showDefaults, options addOptions) (showDefaults, error) {
+	if options.seriesType != "" {
+		seriesType, err := resolveSeriesType(options.seriesType)
+
+		if err != nil {
+			return defaults, err
+		}
+
+		defaults.seriesType = seriesType
+	}
+
+	if options.language != "" {
+		profile, err := resolveLanguageProfile(services, options.language)
+
+		if err != nil {
+			return defaults, err
+		}
+
+		defaults.languageProfileID = profile.id
+		defaults.languageProfile = profile.name
+	}
+
+	if options.seasonFolders != nil {
+		defaults.seasonFolders = *options.seasonFolders
+	}
+
+	return defaults, nil
+}
+
+// seriesRequest adds the fields the sonarr client doesn't know about to a series
+type seriesRequest struct {
+	sonarr.Series
+	LanguageProfileID int `json:"languageProfileId,omitempty"`
+}
+
+// addSeries adds a series to sonarr with a language profile. sonarr v3 won't
+// add a series without one so its first profile is used when none was picked
+func addSeries(services clients, series sonarr.Series, languageProfileID int) error {
+	if languageProfileID == 0 && services.sonarrAPI.root == v3APIRoot {
+		profiles, err := fetchLanguageProfiles(services)
+
+		if err != nil {
+			return err
+		}
+
+		if len(profiles) == 0 {
+			return errors.New("sonarr has no language profiles to add the show with")
+		}
+
+		languageProfileID = profiles[0].ID
+	}
+
+	var added sonarr.Series
+
+	return services.sonarrAPI.post("/series", seriesRequest{
+		Series:            series,
+		LanguageProfileID: languageProfileID,
+	}, &added)
+}
+
+func showLanguageProfiles(commandList d, services clients) func(channelID string, args ...string) {
+	return func(channelID string, args ...string) {
+		// command: languages
+		profiles, err := fetchLanguageProfiles(services)
+
+		if err != nil {
+			channelLogger(channelID).Error("failed to fetch language profiles", "backend", "sonarr", "error", err)
+			commandList.showError(channelID, err.Error())
+			return
+		}
+
+		output := "Here are the available language profiles for sonarr:\n"
+
+		for _, profile := range profiles {
+			output += fmt.Sprintf("\t`id: %d` %s\n", profile.ID, profile.Name)
+		}
+
+		commandList.send(channelID, output)
+	}
+}
+
+func setShowDefaults(commandList d, services clients) func(channelID string, args ...string) {
+	return func(channelID string, args ...string) {
+		// command: defaults show [--type standard|daily|anime] [--language <name|id>] [--season-folders|--no-season-folders]
+		usage := "`defaults show [--type standard|daily|anime] [--language <name|id>] [--season-folders|--no-season-folders]`"
+
+		rest, options, err := parseAddArgs(args)
+
+		if err != nil {
+			commandList.showError(channelID, fmt.Sprintf("%v: %s", err, usage))
+			return
+		}
+
+		if len(rest) != 1 || resolveMediaType(rest[0]) != "show" {
+			commandList.showError(channelID, usage)
+			return
+		}
+
+		if flag := options.addOnly(); flag != "" {
+			commandList.showError(channelID, fmt.Sprintf("`%s` only applies to `add`: %s", flag, usage))
+			return
+		}
+
+		defaults := showDefaultsFor(channelID)
+
+		if options.showOnly() == "" {
+			commandList.send(channelID, "shows added in this channel use "+defaults.String())
+			return
+		}
+
+		defaults, err = applyShowOptions(services, defaults, options)
+
+		if err != nil {
+			commandList.showError(channelID, err.Error())
+			return
+		}
+
+		if err := saveShowDefaults(channelID, defaults); err != nil {
+			channelLogger(channelID).Error("save show defaults failed", "error", err)
+			commandList.showError(channelID, fmt.Sprintf("could not save the defaults: %v", err))
+			return
+		}
+
+		commandList.send(channelID, "shows added in this channel now use "+defaults.String())
+	}
+}
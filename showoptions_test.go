@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// needsV3 is what sonarr v2 answers commands that need language profiles with
+var needsV3 = []string{"fetch sonarr language profiles failed (they need sonarr v3): 404 Not Found"}
+
+func TestLanguages(t *testing.T) {
+	runCommandCases(t, "languages", []commandCase{
+		{
+			name: "profiles",
+			want: []string{"Here are the available language profiles for sonarr:\n" +
+				"\t`id: 1` English\n" +
+				"\t`id: 2` Japanese\n"},
+			request: "sonarr GET /api/v3/languageprofile",
+			legacy:  &commandCase{want: needsV3},
+		},
+		{
+			name: "before sonarr v3",
+			setup: func(h *harness) {
+				h.sonarr.respond("GET /api/v3/languageprofile", 404, "")
+			},
+			want: needsV3,
+		},
+	})
+}
+
+func TestDefaults(t *testing.T) {
+	defaultsAre := func(want showDefaults) func(t *testing.T, h *harness) {
+		return func(t *testing.T, h *harness) {
+			if got := showDefaultsFor(testChannel); got != want {
+				t.Errorf("defaults are %+v, want %+v", got, want)
+			}
+
+			// other channels keep theirs
+			if got := showDefaultsFor("200"); got != (showDefaults{seriesType: "standard", seasonFolders: true}) {
+				t.Errorf("another channel's defaults changed to %+v", got)
+			}
+		}
+	}
+
+	runCommandCases(t, "defaults", []commandCase{
+		{
+			name: "show",
+			args: []string{"show"},
+			want: []string{"shows added in this channel use type: `standard`, language: sonarr's default, season folders: `true`"},
+		},
+		{
+			name:    "change",
+			args:    []string{"tv", "--type", "Anime", "--language", "japanese", "--no-season-folders"},
+			want:    []string{"shows added in this channel now use type: `anime`, language: Japanese `2`, season folders: `false`"},
+			request: "sonarr GET /api/v3/languageprofile",
+			check: func(t *testing.T, h *harness) {
+				want := showDefaults{
+					seriesType:        "anime",
+					languageProfileID: 2,
+					languageProfile:   "Japanese",
+					seasonFolders:     false,
+				}
+
+				defaultsAre(want)(t, h)
+
+				// they're still there after a restart
+				if err := loadShowDefaults(filepath.Dir(channelShowDefaults.path)); err != nil {
+					t.Fatal(err)
+				}
+
+				defaultsAre(want)(t, h)
+			},
+			legacy: &commandCase{
+				want:  needsV3,
+				check: defaultsAre(showDefaults{seriesType: "standard", seasonFolders: true}),
+			},
+		},
+		{
+			name:  "add only flag",
+			args:  []string{"show", "--quality", "HD-1080p"},
+			want:  []string{"`--quality` only applies to `add`: `defaults show [--type standard|daily|anime] [--language <name|id>] [--season-folders|--no-season-folders]`"},
+			check: defaultsAre(showDefaults{seriesType: "standard", seasonFolders: true}),
+		},
+		{
+			name:  "unknown series type",
+			args:  []string{"show", "--type", "sitcom"},
+			want:  []string{"`sitcom` is not a series type, use one of standard, daily, anime"},
+			check: defaultsAre(showDefaults{seriesType: "standard", seasonFolders: true}),
+		},
+		{
+			name:   "unknown language",
+			args:   []string{"show", "--language", "9"},
+			want:   []string{"could not find a language profile with id `9`, try one of `English`, `Japanese`"},
+			check:  defaultsAre(showDefaults{seriesType: "standard", seasonFolders: true}),
+			legacy: &commandCase{want: needsV3},
+		},
+		{
+			name: "movies",
+			args: []string{"movie", "--type", "anime"},
+			want: []string{"`defaults show [--type standard|daily|anime] [--language <name|id>] [--season-folders|--no-season-folders]`"},
+		},
+	})
+}
+
+func TestFailedSaveKeepsShowDefaults(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := loadShowDefaults(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// a directory in the way of the temporary file makes writing it fail
+	if err := os.Mkdir(filepath.Join(dir, "show_defaults.json.tmp"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := saveShowDefaults(testChannel, showDefaults{seriesType: "anime"}); err == nil {
+		t.Fatal("saving show defaults succeeded")
+	}
+
+	if got, want := showDefaultsFor(testChannel), (showDefaults{seriesType: "standard", seasonFolders: true}); got != want {
+		t.Errorf("defaults are %+v after failing to save them, want %+v", got, want)
+	}
+}
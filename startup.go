@@ -0,0 +1,254 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// startup.go makes sure radarr and sonarr can be reached before shart starts taking commands
+
+// strictStartup refuses to start when a backend fails its startup check
+var strictStartup bool
+
+// showOnlyCommands only ever talk to the backend for shows
+var showOnlyCommands = map[string]bool{
+	"episode":   true,
+	"season":    true,
+	"languages": true,
+	"defaults":  true,
+}
+
+// backendCheck is the result of checking a backend at startup
+type backendCheck struct {
+	name   string
+	status systemStatus
+	// major is the major version of the server, 2 for the old api and 3 or later for v3
+	major int
+	err   error
+}
+
+// majorVersion returns the major part of a version like `3.0.1.418`
+func majorVersion(version string) int {
+	major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+
+	if err != nil {
+		return 0
+	}
+
+	return major
+}
+
+// checkBackend connects to a backend, checks the api key and finds out its version.
+// newer servers only answer the v3 api so that's tried first
+func checkBackend(api arrAPI) backendCheck {
+	check := backendCheck{name: api.name}
+
+	if api.url == "" || api.apiKey == "" {
+		check.err = errors.New("missing the url or api key")
+		return check
+	}
+
+	err := api.withRoot(v3APIRoot).get("/system/status", nil, &check.status)
+
+	if err == nil {
+		check.major = majorVersion(check.status.Version)
+		return check
+	}
+
+	// a wrong key is wrong for every version of the api
+	if errors.Is(err, errAPIKeyRejected) {
+		check.err = err
+		return check
+	}
+
+	if err := api.withRoot(legacyAPIRoot).get("/system/status", nil, &check.status); err != nil {
+		check.err = err
+		return check
+	}
+
+	check.major = majorVersion(check.status.Version)
+
+	return check
+}
+
+// checkBackends checks radarr and sonarr and logs a report of how it went
+func checkBackends(services clients) []backendCheck {
+	var checks []backendCheck
+
+	for _, backend := range services.mediaBackends() {
+		api := backend.API()
+		check := checkBackend(api)
+
+		if check.err != nil {
+			logger.Error("backend check failed", "backend", check.name, "url", api.url, "error", check.err)
+		} else {
+			logger.Info("backend check passed",
+				"backend", check.name,
+				"version", check.status.Version,
+				"api", fmt.Sprintf("v%d", check.major),
+				"branch", check.status.Branch)
+		}
+
+		checks = append(checks, check)
+	}
+
+	return checks
+}
+
+// commandBackend returns the name of the backend a command will talk to, going
+// by the media type in its arguments, or an empty string when it can't tell
+func commandBackend(services clients, command string, args []string) string {
+	if showOnlyCommands[command] {
+		args = []string{"show"}
+	}
+
+	for _, arg := range args {
+		if backend, ok := services.mediaBackend(resolveMediaType(arg)); ok {
+			return backend.Name()
+		}
+	}
+
+	return ""
+}
+
+// backendState holds the clients commands talk to and the backends that failed
+// their last check. Failed backends are checked again until they pass, then
+// the api they answer is picked and commands using them are enabled again
+type backendState struct {
+	mu       sync.RWMutex
+	services clients
+	// unavailable are the backends that failed their last check and why
+	unavailable map[string]string
+}
+
+// newBackendState sets up clients for the api versions checks found
+func newBackendState(services clients, checks []backendCheck) *backendState {
+	state := &backendState{
+		services:    withMediaBackends(useDetectedAPIs(services, checks)),
+		unavailable: map[string]string{},
+	}
+
+	for _, check := range checks {
+		if check.err != nil {
+			state.unavailable[check.name] = check.err.Error()
+		}
+	}
+
+	return state
+}
+
+// clients returns the clients as they are now
+func (state *backendState) clients() clients {
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	return state.services
+}
+
+// unavailableBecause returns why a backend failed its last check
+func (state *backendState) unavailableBecause(backend string) (string, bool) {
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	reason, ok := state.unavailable[backend]
+
+	return reason, ok
+}
+
+// recheck checks the unavailable backends again and returns the ones that passed
+func (state *backendState) recheck() []backendCheck {
+	state.mu.RLock()
+
+	var failed []arrAPI
+
+	for _, backend := range state.services.mediaBackends() {
+		if _, ok := state.unavailable[backend.Name()]; ok {
+			failed = append(failed, backend.API())
+		}
+	}
+
+	state.mu.RUnlock()
+
+	var passed []backendCheck
+
+	for _, api := range failed {
+		check := checkBackend(api)
+
+		if check.err != nil {
+			logger.Debug("backend still unavailable", "backend", check.name, "error", check.err)
+
+			state.mu.Lock()
+			state.unavailable[check.name] = check.err.Error()
+			state.mu.Unlock()
+
+			continue
+		}
+
+		logger.Info("backend check passed",
+			"backend", check.name,
+			"version", check.status.Version,
+			"api", fmt.Sprintf("v%d", check.major),
+			"branch", check.status.Branch)
+
+		passed = append(passed, check)
+	}
+
+	if len(passed) == 0 {
+		return nil
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	state.services = withMediaBackends(useDetectedAPIs(state.services, passed))
+
+	for _, check := range passed {
+		delete(state.unavailable, check.name)
+	}
+
+	return passed
+}
+
+// watch rechecks unavailable backends every interval and tells the admin
+// channel when one is back until stop is closed
+func (state *backendState) watch(commandList d, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		for _, check := range state.recheck() {
+			if adminChannel != "" {
+				commandList.notify(adminChannel, fmt.Sprintf("%s `%s` is reachable again, its commands are enabled", check.name, check.status.Version))
+			}
+		}
+	}
+}
+
+// backendUnavailable explains why a command can't run because its backend
+// failed its last check, or returns an empty string when it can run
+func (state *backendState) backendUnavailable(command string, args []string) string {
+	return state.commandDisabled(command, commandBackend(state.clients(), command, args))
+}
+
+// commandDisabled explains why command can't run when backend failed its
+// last check, or returns an empty string when it passed
+func (state *backendState) commandDisabled(command, backend string) string {
+	reason, ok := state.unavailableBecause(backend)
+
+	if !ok {
+		return ""
+	}
+
+	return fmt.Sprintf("`%s` is disabled because %s could not be reached: %s", command, backend, reason)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	radarr "github.com/jrudio/go-radarr-client"
+	sonarr "github.com/jrudio/go-sonarr-client"
+)
+
+// status.go works out whether media is already in radarr or sonarr
+
+// movieStatus describes a movie that is in radarr's library
+func movieStatus(movie radarr.Movie) string {
+	switch {
+	case movie.HasFile || movie.Downloaded:
+		return "downloaded"
+	case movie.Monitored && movie.IsAvailable:
+		return "missing"
+	case movie.Monitored:
+		return "monitored"
+	}
+
+	return "in library"
+}
+
+// showStatus describes a series that is in sonarr's library
+func showStatus(show sonarr.Series) string {
+	switch {
+	case show.EpisodeCount > 0 && show.EpisodeFileCount >= show.EpisodeCount:
+		return "downloaded"
+	case show.Monitored && show.EpisodeFileCount < show.EpisodeCount:
+		return fmt.Sprintf("missing %d of %d episodes", show.EpisodeCount-show.EpisodeFileCount, show.EpisodeCount)
+	case show.Monitored:
+		return "monitored"
+	}
+
+	return "in library"
+}
+
+func showMediaStatus(commandList d, services clients) func(channelID string, args ...string) {
+	backendStatus := showBackendStatus(commandList, services)
+
+	return func(channelID string, args ...string) {
+		// command: status
+		//          status <movie|show> <id|imdb-id|link|title [year]>
+		if len(args) == 0 {
+			backendStatus(channelID)
+			return
+		}
+
+		if len(args) < 2 {
+			commandList.showError(channelID, "`status` or `status <movie|show> <id|imdb-id|link|title [year]>`")
+			return
+		}
+
+		mediaID := strings.TrimSpace(strings.Join(args[1:], " "))
+
+		backend, ok := mediaBackendFor(commandList, services, channelID, resolveMediaType(args[0]))
+
+		if !ok {
+			return
+		}
+
+		id, err := backend.Lookup(mediaID)
+
+		if err != nil {
+			commandList.showError(channelID, err.Error())
+			return
+		}
+
+		items, err := backend.Library()
+
+		if err != nil {
+			channelLogger(channelID).Error("fetch library failed", "backend", backend.Name(), "error", err)
+			commandList.showError(channelID, err.Error())
+			return
+		}
+
+		item, ok := libraryByID(items)[id]
+
+		if !ok {
+			commandList.send(channelID, fmt.Sprintf("`%s` is not in your library", mediaID))
+			return
+		}
+
+		commandList.send(channelID, fmt.Sprintf("`%s (%d)` is %s", item.title, item.year, item.status))
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatus(t *testing.T) {
+	runCommandCases(t, "status", []commandCase{
+		{
+			name: "backends",
+			setup: func(h *harness) {
+				h.sonarr.respond("GET /api/v3/health", 200,
+					`[{"source":"IndexerStatusCheck","type":"warning","message":"Indexers unavailable due to failures: Example Usenet","wikiUrl":""}]`)
+			},
+			check: func(t *testing.T, h *harness) {
+				up := uptime("2020-01-01T00:00:00Z", time.Now())
+
+				want := "**radarr** `3.2.2.5080` (master branch) up " + up + "\n" +
+					"\tstarted from `/app/radarr/bin`\n" +
+					"\tno health issues\n" +
+					"**sonarr** `3.0.10.1567` (main branch) up " + up + "\n" +
+					"\tstarted from `/app/sonarr/bin`\n" +
+					"\twarning: Indexers unavailable due to failures: Example Usenet\n"
+
+				if h.version < 3 {
+					want = "**radarr** `0.2.0.1504` (develop branch) up " + up + "\n" +
+						"\tstarted from `/opt/radarr`\n" +
+						"\tno health issues\n" +
+						"**sonarr** `2.0.0.5344` (master branch) up " + up + "\n" +
+						"\tstarted from `/opt/NzbDrone`\n" +
+						"\twarning: Indexers unavailable due to failures: Example Usenet\n"
+				}
+
+				if got := h.chat.messages(testChannel); len(got) != 1 || got[0] != want {
+					t.Errorf("status replied %q, want %q", got, want)
+				}
+			},
+		},
+		{
+			name:    "downloaded movie",
+			args:    []string{"movie", "sicario", "2015"},
+			want:    []string{"`Sicario (2015)` is downloaded"},
+			request: "radarr GET /api/v3/movie",
+		},
+		{
+			name: "missing movie",
+			args: []string{"film", "693134"},
+			want: []string{"`Dune: Part Two (2024)` is missing"},
+		},
+		{
+			name: "announced movie",
+			args: []string{"movie", "1170608"},
+			want: []string{"`Dune: Part Three (2026)` is monitored"},
+		},
+		{
+			name: "movie not in library",
+			args: []string{"movie", "400535"},
+			want: []string{"`400535` is not in your library"},
+		},
+		{
+			name:    "downloaded show",
+			args:    []string{"show", "breaking", "bad", "2008"},
+			want:    []string{"`Breaking Bad (2008)` is downloaded"},
+			request: "sonarr GET /api/v3/series",
+		},
+		{
+			name: "show missing episodes",
+			args: []string{"show", "273181"},
+			want: []string{"`Better Call Saul (2015)` is missing 13 of 63 episodes"},
+		},
+		{
+			name: "unmonitored show",
+			args: []string{"show", "371980"},
+			want: []string{"`Severance (2022)` is in library"},
+		},
+		{
+			name: "library failing",
+			args: []string{"show", "371980"},
+			setup: func(h *harness) {
+				h.sonarr.respond("GET /api/v3/series", 500, "")
+			},
+			want: []string{"fetch series from sonarr failed: 500 Internal Server Error"},
+		},
+		{
+			name: "missing title",
+			args: []string{"movie"},
+			want: []string{"`status` or `status <movie|show> <id|imdb-id|link|title [year]>`"},
+		},
+	})
+}
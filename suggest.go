@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// suggest.go helps users who mistype a command or media type
+
+// mediaTypes are the media types every command understands, one for each
+// backend in mediaBackendConstructors
+var mediaTypes = listMediaTypes()
+
+func listMediaTypes() []string {
+	var types []string
+
+	for mediaType := range mediaBackendConstructors {
+		types = append(types, mediaType)
+	}
+
+	// map order is random, keep the list stable
+	sort.Strings(types)
+
+	return types
+}
+
+// mediaTypeAliases maps common alternate names to a media type
+var mediaTypeAliases = map[string]string{
+	"film":   "movie",
+	"tv":     "show",
+	"series": "show",
+}
+
+// resolveMediaType returns the media type an arg refers to
+// unknown media types are returned as is so callers can report them
+func resolveMediaType(arg string) string {
+	mediaType := strings.ToLower(arg)
+
+	if alias, ok := mediaTypeAliases[mediaType]; ok {
+		return alias
+	}
+
+	return mediaType
+}
+
+// unknownMediaType builds the reply for a media type we don't recognize
+func unknownMediaType(mediaType string) string {
+	output := fmt.Sprintf("unknown media type: `%s`", mediaType)
+
+	if match := closestMatch(mediaType, mediaTypes); match != "" {
+		output += fmt.Sprintf(", did you mean `%s`?", match)
+	} else {
+		output += fmt.Sprintf("\n\tshould be one of `%s`", strings.Join(mediaTypes, "|"))
+	}
+
+	return output
+}
+
+// closestMatch returns the candidate nearest to word or an empty string
+// when nothing is close enough to be a plausible typo
+func closestMatch(word string, candidates []string) string {
+	word = strings.ToLower(word)
+
+	// allow roughly one typo for every three characters
+	maxDistance := len(word)/3 + 1
+
+	// sort so ties are broken the same way every time
+	sorted := make([]string, len(candidates))
+	copy(sorted, candidates)
+	sort.Strings(sorted)
+
+	match := ""
+	matchDistance := maxDistance + 1
+
+	for _, candidate := range sorted {
+		if distance := editDistance(word, candidate); distance < matchDistance {
+			match = candidate
+			matchDistance = distance
+		}
+	}
+
+	return match
+}
+
+// editDistance is the optimal string alignment distance between a and b:
+// the number of insertions, deletions, substitutions and adjacent
+// transpositions needed to turn one into the other
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+
+	rows := make([][]int, len(s)+1)
+
+	for i := range rows {
+		rows[i] = make([]int, len(t)+1)
+		rows[i][0] = i
+	}
+
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+
+			distance := rows[i-1][j] + 1
+
+			if insertion := rows[i][j-1] + 1; insertion < distance {
+				distance = insertion
+			}
+
+			if substitution := rows[i-1][j-1] + cost; substitution < distance {
+				distance = substitution
+			}
+
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				if transposition := rows[i-2][j-2] + 1; transposition < distance {
+					distance = transposition
+				}
+			}
+
+			rows[i][j] = distance
+		}
+	}
+
+	return rows[len(s)][len(t)]
+}
@@ -72,8 +72,24 @@ func getCredentials() (serviceCredentials, error) {
 	flag.StringVar(&credentials.radarr.apiKey, "radarr-key", "", "api key used for radarr")
 	flag.StringVar(&credentials.sonarr.url, "sonarr-url", "", "url that points to your sonarr app")
 	flag.StringVar(&credentials.sonarr.apiKey, "sonarr-key", "", "api key used for sonarr")
-	flag.BoolVar(&isVerbose, "verbose", false, "output more inforation")
+	flag.BoolVar(&isVerbose, "verbose", false, "output more information (same as -log-level debug)")
+	flag.StringVar(&logLevel, "log-level", "info", "minimum level to log: debug|info|warn|error")
+	flag.StringVar(&logFormat, "log-format", "logfmt", "log output format: logfmt|json")
+	flag.StringVar(&adminUsers, "admins", "", "comma separated discord user ids treated as admins")
+	flag.StringVar(&adminChannel, "admin-channel", "", "discord channel id alerts for admins are posted to")
+	flag.StringVar(&lowDiskThreshold, "low-disk", "10%", "alert the admin channel when a root folder has less free space than this, e.g. 50GB or 10% (empty to disable)")
+	flag.DurationVar(&diskCheckInterval, "disk-check-interval", 15*time.Minute, "how often to check root folders for free space")
+	flag.DurationVar(&healthCheckInterval, "health-check-interval", 5*time.Minute, "how often to check radarr and sonarr for health issues")
+	flag.StringVar(&rateLimitUser, "rate-limit-user", "10/1m", "commands a user may run, as <count>/<duration> (empty to disable)")
+	flag.StringVar(&rateLimitChannel, "rate-limit-channel", "", "commands a channel may run, as <count>/<duration> (empty to disable)")
+	flag.StringVar(&rateLimitCommands, "rate-limit-commands", "search=5/1m,discover=2/1m", "per user cooldowns for single commands, as <command>=<count>/<duration>,...")
+	flag.StringVar(&dataDir, "data-dir", "data", "directory shart stores its state in")
+	flag.StringVar(&expireAfter, "expire", "", "delete replies and the messages that asked for them after a while, as <command|help|error>=<duration>,...")
+	flag.StringVar(&seriesFolderFormat, "series-folder-format", "{Title}", "folder name for added shows, using {Title}, {Year} and {TvdbId}")
+	flag.BoolVar(&strictStartup, "strict", false, "refuse to start when radarr or sonarr can't be reached")
+	flag.StringVar(&httpAddr, "http-addr", ":6969", "address to serve metrics and other http endpoints on")
 	versionFlag = flag.Bool("version", false, "get program version")
+	healthcheckFlag := flag.Bool("healthcheck", false, "check the health of a running shart via -http-addr and exit")
 
 	flag.Parse()
 
@@ -83,8 +99,21 @@ func getCredentials() (serviceCredentials, error) {
 		os.Exit(0)
 	}
 
+	if *healthcheckFlag {
+		os.Exit(runHealthcheck(httpAddr))
+	}
+
+	// a ticker panics on anything but a positive interval
+	if diskCheckInterval <= 0 {
+		return credentials, fmt.Errorf("-disk-check-interval must be longer than 0, got %s", diskCheckInterval)
+	}
+
+	if healthCheckInterval <= 0 {
+		return credentials, fmt.Errorf("-health-check-interval must be longer than 0, got %s", healthCheckInterval)
+	}
+
 	if credentials.shart.token == "" {
-		return credentials, errors.New("a token is required")
+		return credentials, errors.New(errTokenRequired)
 	}
 
 	return credentials, nil
@@ -166,11 +195,40 @@ func initializeClients(credentials serviceCredentials) (clients, error) {
 		return services, errors.New("sonarr client failed: " + err.Error())
 	}
 
-	services.sonarr = sonarrClient
+	services.radarrAPI = newArrAPI("radarr", credentials.radarr.url, credentials.radarr.apiKey)
+	services.sonarrAPI = newArrAPI("sonarr", credentials.sonarr.url, credentials.sonarr.apiKey)
+
+	services.sonarr = sonarrV2{Sonarr: sonarrClient, api: services.sonarrAPI}
 
-	return services, nil
+	return withMediaBackends(services), nil
 }
 
-func logPrint(chanID, message string) {
-	fmt.Printf("%s - channel id: %s - %s\n", time.Now().String(), chanID, message)
+// formatBytes turns a byte count into something readable like `1.4 GB`
+func formatBytes(size int64) string {
+	const unit = 1024
+
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// writeFileAtomic replaces the file at path with contents. It writes to a
+// temporary file first and renames it so a crash can't leave a half written file
+func writeFileAtomic(path string, contents []byte) error {
+	tmp := path + ".tmp"
+
+	if err := ioutil.WriteFile(tmp, contents, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
 }
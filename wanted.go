@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// wanted.go lists and searches for missing media and media below its quality cutoff
+
+const (
+	wantedPageSize = 20
+	// commands are polled until they finish so the channel hears how they went
+	commandPollInterval = 5 * time.Second
+	commandPollTimeout  = 10 * time.Minute
+)
+
+// wantedPage is a page of radarr's or sonarr's wanted list
+type wantedPage struct {
+	Page         int            `json:"page"`
+	PageSize     int            `json:"pageSize"`
+	TotalRecords int            `json:"totalRecords"`
+	Records      []wantedRecord `json:"records"`
+}
+
+// wantedRecord is a movie from radarr or an episode from sonarr
+type wantedRecord struct {
+	Title         string `json:"title"`
+	Year          int    `json:"year"`
+	TmdbID        int    `json:"tmdbId"`
+	SeasonNumber  int    `json:"seasonNumber"`
+	EpisodeNumber int    `json:"episodeNumber"`
+	AirDate       string `json:"airDate"`
+	Series        *struct {
+		Title  string `json:"title"`
+		TvdbID int    `json:"tvdbId"`
+	} `json:"series"`
+}
+
+func (record wantedRecord) String() string {
+	if record.Series != nil {
+		return fmt.Sprintf("%s S%02dE%02d *%s* (aired %s)",
+			record.Series.Title,
+			record.SeasonNumber,
+			record.EpisodeNumber,
+			record.Title,
+			record.AirDate)
+	}
+
+	return fmt.Sprintf("%s (%d) `%d`", record.Title, record.Year, record.TmdbID)
+}
+
+// radarrWantedCommands and sonarrWantedCommands search for everything on a wanted list
+var (
+	radarrWantedCommands = map[string]string{
+		"missing": "MissingMoviesSearch",
+		"cutoff":  "CutoffUnmetMoviesSearch",
+	}
+	sonarrWantedCommands = map[string]string{
+		"missing": "MissingEpisodeSearch",
+		"cutoff":  "CutoffUnmetEpisodeSearch",
+	}
+)
+
+// fetchWanted gets a page of a wanted list, sorted by sortKey, sticking to monitored media
+func fetchWanted(api arrAPI, list string, page int, params url.Values) (wantedPage, error) {
+	params.Set("page", strconv.Itoa(page))
+	params.Set("pageSize", strconv.Itoa(wantedPageSize))
+	params.Set("filterKey", "monitored")
+	params.Set("filterValue", "true")
+
+	var wanted wantedPage
+
+	err := api.get("/wanted/"+list, params, &wanted)
+
+	return wanted, err
+}
+
+func (backend radarrBackend) Wanted(list string, page int) (wantedPage, error) {
+	params := url.Values{}
+	params.Set("sortKey", "title")
+	params.Set("sortDir", "asc")
+
+	return fetchWanted(backend.API(), list, page, params)
+}
+
+func (backend sonarrBackend) Wanted(list string, page int) (wantedPage, error) {
+	// newest episodes first
+	params := url.Values{}
+	params.Set("sortKey", "airDateUtc")
+	params.Set("sortDir", "desc")
+	params.Set("includeSeries", "true")
+
+	return fetchWanted(backend.API(), list, page, params)
+}
+
+func (backend radarrBackend) SearchWanted(list string) (string, commandStatus, error) {
+	name := radarrWantedCommands[list]
+
+	// radarr searches every movie unless told to stick to monitored ones
+	command, err := backend.API().runCommand(name, map[string]interface{}{
+		"filterKey":   "monitored",
+		"filterValue": "true",
+	})
+
+	return name, command, err
+}
+
+func (backend sonarrBackend) SearchWanted(list string) (string, commandStatus, error) {
+	name := sonarrWantedCommands[list]
+
+	command, err := backend.API().runCommand(name, map[string]interface{}{})
+
+	return name, command, err
+}
+
+// parseWantedArgs reads `<movie|show> [missing|cutoff] [page]`
+func parseWantedArgs(args []string) (mediaType, list string, page int, err error) {
+	if len(args) == 0 {
+		return "", "", 0, fmt.Errorf("missing the media type")
+	}
+
+	mediaType = resolveMediaType(args[0])
+	list = "missing"
+	page = 1
+
+	for _, arg := range args[1:] {
+		switch arg {
+		case "missing", "cutoff":
+			list = arg
+		default:
+			page, err = strconv.Atoi(arg)
+
+			if err != nil || page < 1 {
+				return mediaType, list, page, fmt.Errorf("`%s` is not a list (missing or cutoff) or a page number", arg)
+			}
+		}
+	}
+
+	return mediaType, list, page, nil
+}
+
+func showWanted(commandList d, services clients) func(channelID string, args ...string) {
+	return func(channelID string, args ...string) {
+		// command: wanted <movie|show> [missing|cutoff] [page]
+		//          wanted search <movie|show> [missing|cutoff]
+		usage := "`wanted [search] <movie|show> [missing|cutoff] [page]`"
+
+		searchFor := len(args) > 0 && args[0] == "search"
+
+		if searchFor {
+			args = args[1:]
+		}
+
+		mediaType, list, page, err := parseWantedArgs(args)
+
+		if err != nil {
+			commandList.showError(channelID, fmt.Sprintf("%v: %s", err, usage))
+			return
+		}
+
+		backend, ok := mediaBackendFor(commandList, services, channelID, mediaType)
+
+		if !ok {
+			return
+		}
+
+		api := backend.API()
+
+		if searchFor {
+			name, command, err := backend.SearchWanted(list)
+
+			if err != nil {
+				channelLogger(channelID).Error("wanted search failed", "backend", api.name, "command", name, "error", err)
+				commandList.showError(channelID, fmt.Sprintf("%s could not start `%s`: %v", api.name, name, err))
+				return
+			}
+
+			commandList.send(channelID, fmt.Sprintf("started `%s` (command `%d` %s), I'll let you know when it's done", name, command.ID, command.state()))
+
+			go func() {
+				status, err := api.waitForCommand(command.ID, commandPollInterval, commandPollTimeout)
+
+				if err != nil {
+					channelLogger(channelID).Warn("poll command failed", "backend", api.name, "command", name, "error", err)
+					commandList.notify(channelID, fmt.Sprintf("`%s` (command `%d`): %v", name, command.ID, err))
+					return
+				}
+
+				commandList.notify(channelID, fmt.Sprintf("`%s` (command `%d`) %s", name, command.ID, status.state()))
+			}()
+
+			return
+		}
+
+		wanted, err := backend.Wanted(list, page)
+
+		if err != nil {
+			channelLogger(channelID).Error("fetch wanted failed", "backend", api.name, "error", err)
+			commandList.showError(channelID, fmt.Sprintf("fetch wanted list from %s failed: %v", api.name, err))
+			return
+		}
+
+		pages := (wanted.TotalRecords + wantedPageSize - 1) / wantedPageSize
+
+		if len(wanted.Records) == 0 {
+			commandList.send(channelID, fmt.Sprintf("nothing %s on page %d", list, page))
+			return
+		}
+
+		output := fmt.Sprintf("%d %s (page %d of %d):\n", wanted.TotalRecords, list, page, pages)
+
+		for _, record := range wanted.Records {
+			output += fmt.Sprintf("- %s\n", record)
+		}
+
+		if page < pages {
+			output += fmt.Sprintf("\n`wanted %s %s %d` for more", mediaType, list, page+1)
+		}
+
+		commandList.send(channelID, output)
+	}
+}
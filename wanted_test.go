@@ -0,0 +1,105 @@
+package main
+
+import "testing"
+
+func TestWanted(t *testing.T) {
+	// notified waits for the message posted once a search command finishes
+	notified := func(want string) func(t *testing.T, h *harness) {
+		return func(t *testing.T, h *harness) {
+			messages := h.waitForMessages(testChannel, 2)
+
+			if len(messages) != 2 || messages[1] != want {
+				t.Errorf("channel got %q, want the notification %q", messages, want)
+			}
+		}
+	}
+
+	runCommandCases(t, "wanted", []commandCase{
+		{
+			name: "missing movies",
+			args: []string{"movie"},
+			want: []string{"2 missing (page 1 of 1):\n" +
+				"- Dune: Part Three (2026) `1170608`\n" +
+				"- Dune: Part Two (2024) `693134`\n"},
+			request: "radarr GET /api/v3/wanted/missing",
+			query: map[string]string{
+				"page":        "1",
+				"pageSize":    "20",
+				"filterKey":   "monitored",
+				"filterValue": "true",
+				"sortKey":     "title",
+				"sortDir":     "asc",
+			},
+		},
+		{
+			name: "missing episodes",
+			args: []string{"show", "missing"},
+			want: []string{"21 missing (page 1 of 2):\n" +
+				"- Better Call Saul S06E13 *Saul Gone* (aired 2022-08-15)\n" +
+				"- Better Call Saul S06E12 *Waterworks* (aired 2022-08-08)\n" +
+				"\n`wanted show missing 2` for more"},
+			request: "sonarr GET /api/v3/wanted/missing",
+			query: map[string]string{
+				"sortKey":       "airDateUtc",
+				"sortDir":       "desc",
+				"includeSeries": "true",
+			},
+		},
+		{
+			name:    "nothing below cutoff",
+			args:    []string{"movie", "cutoff"},
+			want:    []string{"nothing cutoff on page 1"},
+			request: "radarr GET /api/v3/wanted/cutoff",
+		},
+		{
+			name: "backend error",
+			args: []string{"show", "cutoff", "2"},
+			want: []string{"fetch wanted list from sonarr failed: 404 Not Found"},
+		},
+		{
+			name: "unknown list",
+			args: []string{"movie", "sometimes"},
+			want: []string{"`sometimes` is not a list (missing or cutoff) or a page number: `wanted [search] <movie|show> [missing|cutoff] [page]`"},
+		},
+		{
+			name:    "search movies",
+			args:    []string{"search", "movie"},
+			want:    []string{"started `MissingMoviesSearch` (command `42` queued), I'll let you know when it's done"},
+			request: "radarr POST /api/v3/command",
+			body: map[string]interface{}{
+				"name":        "MissingMoviesSearch",
+				"filterKey":   "monitored",
+				"filterValue": "true",
+			},
+			check: notified("`MissingMoviesSearch` (command `42`) completed"),
+		},
+		{
+			name:    "search episodes below cutoff",
+			args:    []string{"search", "show", "cutoff"},
+			want:    []string{"started `CutoffUnmetEpisodeSearch` (command `7` queued), I'll let you know when it's done"},
+			request: "sonarr POST /api/v3/command",
+			body: map[string]interface{}{
+				"name":      "CutoffUnmetEpisodeSearch",
+				"filterKey": nil,
+			},
+			check: notified("`CutoffUnmetEpisodeSearch` (command `7`) completed"),
+		},
+		{
+			name: "search losing track of the command",
+			args: []string{"search", "movie"},
+			setup: func(h *harness) {
+				h.radarr.respond("GET /api/v3/command/42", 500, "")
+			},
+			want:  []string{"started `MissingMoviesSearch` (command `42` queued), I'll let you know when it's done"},
+			check: notified("`MissingMoviesSearch` (command `42`): 500 Internal Server Error"),
+		},
+		{
+			name: "search failing",
+			args: []string{"search", "movie"},
+			setup: func(h *harness) {
+				h.radarr.respond("POST /api/v3/command", 500, "")
+			},
+			want: []string{"radarr could not start `MissingMoviesSearch`: 500 Internal Server Error"},
+		},
+	})
+}